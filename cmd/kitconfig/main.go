@@ -0,0 +1,185 @@
+// Command kitconfig manages the encrypted ENC(...) values of the config files.
+//
+//	kitconfig genkey
+//	kitconfig encrypt [-key-file f] < value
+//	kitconfig encrypt [-key-file f] -f config.yaml <key>...
+//	kitconfig decrypt [-key-file f] [<value>]
+//	kitconfig decrypt [-key-file f] -f config.yaml
+//	kitconfig rekey [-key-file f] -new-key-file f -f config.yaml
+//
+// The master key is read from -key-file, or from KIT_CONFIG_KEY or KIT_CONFIG_KEY_FILE.
+// The plain value to encrypt is read from stdin, so that it is kept out of the
+// shell history and the process list, as is the value to decrypt if none is given.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atong007/kit/config"
+	"github.com/atong007/kit/crypto"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "genkey":
+		err = genKey()
+	case "encrypt":
+		err = encrypt(args)
+	case "decrypt":
+		err = decrypt(args)
+	case "rekey":
+		err = rekey(args)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kitconfig:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kitconfig genkey|encrypt|decrypt|rekey [flags] [args]")
+	os.Exit(2)
+}
+
+func genKey() error {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(key))
+	return nil
+}
+
+type flags struct {
+	fs      *flag.FlagSet
+	keyFile *string
+	file    *string
+}
+
+func newFlags(name string) flags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return flags{
+		fs:      fs,
+		keyFile: fs.String("key-file", "", "the file of the master key, KIT_CONFIG_KEY or KIT_CONFIG_KEY_FILE is used otherwise"),
+		file:    fs.String("f", "", "the YAML config file to rewrite"),
+	}
+}
+
+func (f flags) cryptor() (crypto.Cryptor, error) {
+	var key string
+	var err error
+	if *f.keyFile != "" {
+		key, err = config.ReadKeyFile(*f.keyFile)
+	} else {
+		key, err = config.MasterKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config.NewCryptor(key)
+}
+
+func encrypt(args []string) error {
+	f := newFlags("encrypt")
+	_ = f.fs.Parse(args)
+	c, err := f.cryptor()
+	if err != nil {
+		return err
+	}
+	if *f.file != "" {
+		if f.fs.NArg() == 0 {
+			return errors.New("no config key to encrypt")
+		}
+		return config.EncryptFile(*f.file, f.fs.Args(), c)
+	}
+	if f.fs.NArg() != 0 {
+		return errors.New("the value to encrypt is read from stdin")
+	}
+	plain, err := readValue()
+	if err != nil {
+		return err
+	}
+	enc, err := config.Encrypt(c, plain)
+	if err != nil {
+		return err
+	}
+	fmt.Println(enc)
+	return nil
+}
+
+func decrypt(args []string) error {
+	f := newFlags("decrypt")
+	_ = f.fs.Parse(args)
+	c, err := f.cryptor()
+	if err != nil {
+		return err
+	}
+	if *f.file != "" {
+		return config.DecryptFile(*f.file, c)
+	}
+	var v string
+	switch f.fs.NArg() {
+	case 0:
+		if v, err = readValue(); err != nil {
+			return err
+		}
+	case 1:
+		v = f.fs.Arg(0)
+	default:
+		return errors.New("expected a single value to decrypt")
+	}
+	plain, err := config.Decrypt(c, v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(plain)
+	return nil
+}
+
+// readValue reads a value from stdin, without its trailing newline
+func readValue() (string, error) {
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("error reading stdin: %w", err)
+	}
+	v := strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r")
+	if v == "" {
+		return "", errors.New("no value read from stdin")
+	}
+	return v, nil
+}
+
+func rekey(args []string) error {
+	f := newFlags("rekey")
+	newKeyFile := f.fs.String("new-key-file", "", "the file of the new master key")
+	_ = f.fs.Parse(args)
+	if *f.file == "" || *newKeyFile == "" {
+		return errors.New("rekey requires -f and -new-key-file")
+	}
+	old, err := f.cryptor()
+	if err != nil {
+		return err
+	}
+	key, err := config.ReadKeyFile(*newKeyFile)
+	if err != nil {
+		return err
+	}
+	c, err := config.NewCryptor(key)
+	if err != nil {
+		return err
+	}
+	return config.RekeyFile(*f.file, old, c)
+}
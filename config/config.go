@@ -1,21 +1,14 @@
 package config
 
-import (
-	"fmt"
-	"github.com/spf13/viper"
-)
-
 type Config struct {
 }
 
+// LoadAndRead reads the file into T, the default tags of T are applied for
+// the missing keys and the result is checked with its validate tags
 func LoadAndRead[T any](file string) (*T, error) {
-	viper.SetConfigFile(file)
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("error loading config file: %w", err)
-	}
-	var conf T
-	if err := viper.Unmarshal(&conf); err != nil {
-		return nil, fmt.Errorf("error unmarshal config file: %w", err)
+	loaded, err := Load[T](LayeredOptions{File: file})
+	if err != nil {
+		return nil, err
 	}
-	return &conf, nil
+	return loaded.Config, nil
 }
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	govalidator "github.com/go-playground/validator/v10"
+
+	"github.com/atong007/kit/validator"
+)
+
+// tagDefaults returns the values of the default tags of t keyed by config key paths
+func tagDefaults(t reflect.Type) map[string]interface{} {
+	settings := map[string]interface{}{}
+	walkFields(t, func(f field) {
+		if v, ok := f.Tag.Lookup("default"); ok {
+			settings[f.key] = v
+		}
+	})
+	return settings
+}
+
+// check validates conf with the validate tags, the errors are translated and name the config key of the field
+func check(conf interface{}) error {
+	t := reflect.TypeOf(conf)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	err := validator.Check(conf)
+	var errs govalidator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	keys := map[string]string{}
+	walkFields(t, func(f field) {
+		keys[f.path] = f.key
+	})
+	msgs := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		// the struct namespace starts with the name of the type
+		_, path, _ := strings.Cut(fe.StructNamespace(), ".")
+		key, ok := keys[path]
+		if !ok {
+			key = strings.ToLower(path)
+		}
+		msgs = append(msgs, fmt.Sprintf("config key %s: %s", key, validator.Translate(govalidator.ValidationErrors{fe})))
+	}
+	return fmt.Errorf("invalid config: %s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type serverConf struct {
+	Addr    string        `mapstructure:"addr" default:":8080"`
+	Timeout time.Duration `mapstructure:"timeout" default:"5s"`
+	URL     string        `mapstructure:"url" validate:"omitempty,url"`
+}
+
+type checkedConf struct {
+	Name   string     `mapstructure:"name" validate:"required"`
+	Server serverConf `mapstructure:"server"`
+	DB     struct {
+		Host     string   `mapstructure:"host" validate:"required"`
+		Port     int      `mapstructure:"port" default:"3306"`
+		MaxConns int      `mapstructure:"max_conns" default:"5" validate:"min=1,max=100"`
+		Tags     []string `mapstructure:"tags" default:"a,b"`
+	} `mapstructure:"db"`
+}
+
+func TestLoadAndRead(t *testing.T) {
+	conf, err := LoadAndRead[checkedConf]("testdata/config.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "kit", conf.Name)
+	assert.Equal(t, ":8080", conf.Server.Addr)
+	assert.Equal(t, time.Second*5, conf.Server.Timeout)
+	assert.Equal(t, "localhost", conf.DB.Host)
+	assert.Equal(t, 3306, conf.DB.Port)
+	// the file overrides the default tag
+	assert.Equal(t, 10, conf.DB.MaxConns)
+	assert.Equal(t, []string{"a", "b"}, conf.DB.Tags)
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults map[string]interface{}
+		keys     []string
+	}{
+		{"valid", nil, nil},
+		{"required", map[string]interface{}{"name": ""}, []string{"config key name:"}},
+		{"max", map[string]interface{}{"db.max_conns": 1000}, []string{"config key db.max_conns:"}},
+		{"url", map[string]interface{}{"server.url": "not a url"}, []string{"config key server.url:"}},
+		{
+			"several",
+			map[string]interface{}{"name": "", "db.max_conns": 0},
+			[]string{"config key name:", "config key db.max_conns:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// no file is read, the explicit defaults set the required keys
+			defaults := map[string]interface{}{"name": "kit", "db.host": "localhost"}
+			for k, v := range tt.defaults {
+				defaults[k] = v
+			}
+			_, err := Load[checkedConf](LayeredOptions{Defaults: defaults})
+			if len(tt.keys) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, key := range tt.keys {
+				assert.Contains(t, err.Error(), key)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces the values of the fields tagged with secret:"true", the
+// nested structs and maps tagged as secret are replaced as a whole
+const Redacted = "******"
+
+// isSecret reports whether the field is tagged as secret
+func isSecret(f field) bool {
+	v, ok := f.Tag.Lookup("secret")
+	return ok && v != "false"
+}
+
+// redactor is implemented by Loaded, which also redacts its encrypted values
+type redactor interface {
+	redact() map[string]interface{}
+}
+
+func (l *Loaded[T]) redact() map[string]interface{} {
+	return redact(l.Config, l.Encrypted)
+}
+
+// Redact returns conf, a struct, a pointer to one or a *Loaded, as nested maps
+// keyed by config keys. The non-zero secret fields are replaced by Redacted,
+// as are the fields of the values of a *Loaded which were encrypted.
+func Redact(conf interface{}) map[string]interface{} {
+	if r, ok := conf.(redactor); ok {
+		return r.redact()
+	}
+	return redact(conf, nil)
+}
+
+// redact redacts the secret fields and the fields of the encrypted keys,
+// or holding them for the maps
+func redact(conf interface{}, encrypted map[string]bool) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(conf))
+	flat := map[string]interface{}{}
+	if v.Kind() != reflect.Struct {
+		return flat
+	}
+	walkFields(v.Type(), func(f field) {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// a nil pointer to a nested struct
+			return
+		}
+		if key, depth, ok := secretParent(v.Type(), f); ok {
+			if pv, _ := v.FieldByIndexErr(f.index[:depth]); !pv.IsZero() {
+				flat[key] = Redacted
+				return
+			}
+		}
+		switch {
+		case (isSecret(f) || holdsKey(f.key, encrypted)) && !fv.IsZero():
+			flat[f.key] = Redacted
+		case fv.Type() == reflect.TypeOf(time.Duration(0)):
+			flat[f.key] = fv.Interface().(time.Duration).String()
+		default:
+			flat[f.key] = fv.Interface()
+		}
+	})
+	return unflatten(flat)
+}
+
+// secretParent returns the key and the index depth of the outermost struct
+// holding f which is tagged as secret
+func secretParent(t reflect.Type, f field) (string, int, bool) {
+	var keys []string
+	for depth := 1; depth < len(f.index); depth++ {
+		sf := t.FieldByIndex(f.index[:depth])
+		name, squash := fieldKey(sf)
+		if !squash {
+			keys = append(keys, name)
+		}
+		if isSecret(field{StructField: sf}) {
+			return strings.Join(keys, "."), depth, true
+		}
+	}
+	return "", 0, false
+}
+
+// holdsKey reports whether one of the keys is key or below it
+func holdsKey(key string, keys map[string]bool) bool {
+	if keys[key] {
+		return true
+	}
+	for k := range keys {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Dump renders conf with the secrets redacted, the format is either FormatYAML or FormatJSON
+func Dump(conf interface{}, format Format) ([]byte, error) {
+	m := Redact(conf)
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(m)
+	case FormatJSON:
+		return json.MarshalIndent(m, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported dump format %s", format)
+	}
+}
+
+// Handler serves the config returned by get with the secrets redacted, see Redact,
+// as YAML if the format query parameter is yaml or YAML is accepted, as JSON otherwise.
+// It is meant for the admin endpoints, which must not be exposed publicly.
+func Handler(get func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format, contentType := FormatJSON, "application/json"
+		if f := r.URL.Query().Get("format"); f == string(FormatYAML) ||
+			f == "" && strings.Contains(r.Header.Get("Accept"), "yaml") {
+			format, contentType = FormatYAML, "application/yaml"
+		}
+		b, err := Dump(get(), format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(b)
+	})
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type docConf struct {
+	Name string `mapstructure:"name" validate:"required" desc:"the service name"`
+	Mode string `mapstructure:"mode" default:"dev" validate:"oneof=dev prod"`
+	DB   struct {
+		Host     string        `mapstructure:"host" default:"localhost"`
+		Password string        `mapstructure:"password" secret:"true"`
+		MaxConns int           `mapstructure:"max_conns" default:"5" validate:"min=1,max=100"`
+		Timeout  time.Duration `mapstructure:"timeout" default:"3s"`
+	} `mapstructure:"db"`
+	Token *struct {
+		Key string `mapstructure:"key" secret:"true"`
+	} `mapstructure:"token"`
+}
+
+func TestDump(t *testing.T) {
+	var conf docConf
+	conf.Name = "kit"
+	conf.DB.Password = "secret"
+	conf.DB.Timeout = time.Second * 3
+
+	b, err := Dump(&conf, FormatYAML)
+	require.NoError(t, err)
+	assert.Equal(t, `db:
+    host: ""
+    max_conns: 0
+    password: '******'
+    timeout: 3s
+mode: ""
+name: kit
+`, string(b))
+
+	b, err = Dump(conf, FormatJSON)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+
+	_, err = Dump(conf, FormatTOML)
+	assert.Error(t, err)
+}
+
+func TestRedact_SecretSubtree(t *testing.T) {
+	type credentials struct {
+		User     string `mapstructure:"user"`
+		Password string `mapstructure:"password"`
+	}
+	conf := struct {
+		Name   string            `mapstructure:"name"`
+		DB     credentials       `mapstructure:"db" secret:"true"`
+		Mail   *credentials      `mapstructure:"mail" secret:"true"`
+		Cache  credentials       `mapstructure:"cache" secret:"true"`
+		Tokens map[string]string `mapstructure:"tokens" secret:"true"`
+	}{
+		Name:   "kit",
+		DB:     credentials{User: "root", Password: "db-secret"},
+		Mail:   &credentials{Password: "mail-secret"},
+		Tokens: map[string]string{"api": "token-secret"},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"name":   "kit",
+		"db":     Redacted,
+		"mail":   Redacted,
+		"cache":  map[string]interface{}{"user": "", "password": ""},
+		"tokens": Redacted,
+	}, Redact(conf))
+}
+
+func TestRedact_Encrypted(t *testing.T) {
+	c, err := NewCryptor(testKey)
+	require.NoError(t, err)
+	enc, err := Encrypt(c, "secret")
+	require.NoError(t, err)
+	// the fields of the encrypted values have no secret tag
+	file := writeConfig(t, "db:\n  host: localhost\n  password: "+enc+"\nmail:\n  password: plain\n")
+	loaded, err := Load[secretConf](LayeredOptions{File: file, Cryptor: c})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"db.password": true}, loaded.Encrypted)
+
+	assert.Equal(t, map[string]interface{}{
+		"db":   map[string]interface{}{"host": "localhost", "password": Redacted},
+		"mail": map[string]interface{}{"password": "plain"},
+	}, Redact(loaded))
+
+	rec := httptest.NewRecorder()
+	Handler(func() interface{} { return loaded }).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	assert.NotContains(t, rec.Body.String(), "secret")
+}
+
+func TestHandler(t *testing.T) {
+	var conf docConf
+	conf.Token = &struct {
+		Key string `mapstructure:"key" secret:"true"`
+	}{Key: "paseto"}
+	h := Handler(func() interface{} { return &conf })
+
+	tests := []struct {
+		target, accept, contentType string
+	}{
+		{"/config", "", "application/json"},
+		{"/config?format=yaml", "", "application/yaml"},
+		{"/config", "application/yaml", "application/yaml"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), Redacted)
+		assert.NotContains(t, w.Body.String(), "paseto")
+	}
+}
+
+func TestReference(t *testing.T) {
+	docs := Reference(docConf{})
+	require.Len(t, docs, 7)
+	assert.Equal(t, FieldDoc{Key: "name", Type: "string", Description: "the service name", Required: true, Validate: "required"}, docs[0])
+	assert.Equal(t, FieldDoc{Key: "db.timeout", Type: "duration", Default: "3s"}, docs[5])
+	assert.True(t, docs[3].Secret)
+	assert.Contains(t, Markdown(docConf{}), "| `db.max_conns` | int | `5` |  |  |\n")
+
+	b, err := JSONSchema(&docConf{})
+	require.NoError(t, err)
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			Mode map[string]interface{} `json:"mode"`
+			DB   struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"db"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(b, &schema))
+	assert.Equal(t, []string{"name"}, schema.Required)
+	assert.Equal(t, []interface{}{"dev", "prod"}, schema.Properties.Mode["enum"])
+	assert.Equal(t, map[string]interface{}{"type": "integer", "default": 5.0, "minimum": 1.0, "maximum": 100.0},
+		schema.Properties.DB.Properties["max_conns"])
+	assert.Equal(t, true, schema.Properties.DB.Properties["password"]["writeOnly"])
+}
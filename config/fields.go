@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field is a leaf field of a config struct
+type field struct {
+	// key is the dotted config key path, named after the mapstructure tags or lower cased field names
+	key string
+	// path is the dotted path of the Go field names
+	path string
+	// index is the index sequence of the field in the walked struct, for reflect.Value.FieldByIndexErr
+	index []int
+	reflect.StructField
+}
+
+// walkFields calls fn for every leaf field of the struct type t, the nested
+// structs other than time.Time are walked into
+func walkFields(t reflect.Type, fn func(f field)) {
+	walkFieldsPrefix(t, "", "", nil, fn)
+}
+
+func walkFieldsPrefix(t reflect.Type, keyPrefix, pathPrefix string, index []int, fn func(f field)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, squash := fieldKey(f)
+		if name == "-" {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			if squash {
+				walkFieldsPrefix(ft, keyPrefix, pathPrefix+f.Name+".", idx, fn)
+			} else {
+				walkFieldsPrefix(ft, keyPrefix+name+".", pathPrefix+f.Name+".", idx, fn)
+			}
+			continue
+		}
+		fn(field{key: keyPrefix + name, path: pathPrefix + f.Name, index: idx, StructField: f})
+	}
+}
+
+// fieldKey returns the config key of the field and whether it is squashed into its parent
+func fieldKey(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("mapstructure")
+	name, opts, _ := strings.Cut(tag, ",")
+	squash := f.Anonymous && strings.Contains(opts, "squash")
+	if name == "" {
+		name = f.Name
+	}
+	return strings.ToLower(name), squash
+}
+
+// structKeys returns the config key paths of the fields of t
+func structKeys(t reflect.Type) map[string]bool {
+	keys := map[string]bool{}
+	walkFields(t, func(f field) {
+		keys[f.key] = true
+	})
+	return keys
+}
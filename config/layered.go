@@ -0,0 +1,319 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/atong007/kit/crypto"
+)
+
+// SourceKind is the layer which set a config key
+type SourceKind string
+
+// The layers of Load, from the lowest to the highest precedence
+const (
+	SourceDefault SourceKind = "default"
+	SourceFile    SourceKind = "file"
+	SourceProfile SourceKind = "profile"
+	SourceRemote  SourceKind = "remote"
+	SourceEnv     SourceKind = "env"
+	SourceFlag    SourceKind = "flag"
+)
+
+// LayeredOptions are the layers read by Load, every layer overrides the keys of the previous ones
+type LayeredOptions struct {
+	// Defaults are the default values keyed by dotted key paths or nested maps
+	Defaults map[string]interface{}
+	// File is the base config file, e.g. config.yaml
+	File string
+	// Format is the format of File and its profile, it is guessed from the extension of File if empty
+	Format Format
+	// Profile selects the profile file next to the base file, e.g. config.prod.yaml for "prod"
+	Profile string
+	// Remote are the remote sources, such as a config service, read in order
+	Remote []Source
+	// EnvPrefix enables the env layer, APP_DB_HOST sets db.host for the prefix "APP".
+	// Only the keys of the config struct and of the layers below are read from the env.
+	EnvPrefix string
+	// Flags are the command-line flags, the changed flags set the keys of their names
+	Flags *pflag.FlagSet
+	// Cryptor decrypts the ENC(...) values, the AesGCM of MasterKey is used if nil
+	Cryptor crypto.Cryptor
+}
+
+// Loaded is a loaded config and the source of each of its keys
+type Loaded[T any] struct {
+	Config  *T
+	Sources map[string]SourceKind
+	// Encrypted are the keys whose values were decrypted, they are redacted by Redact
+	Encrypted map[string]bool
+}
+
+// Source returns the layer which set the dotted key path, empty if none did
+func (l *Loaded[T]) Source(key string) SourceKind {
+	return l.Sources[strings.ToLower(key)]
+}
+
+// Load reads the layers of opts into T, the default tags of T are applied
+// below the other layers and the result is checked with its validate tags
+func Load[T any](opts LayeredOptions) (*Loaded[T], error) {
+	remote, err := readRemote(opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	return load[T](opts, remote)
+}
+
+// readRemote reads the settings of the remote sources
+func readRemote(sources []Source) ([]map[string]interface{}, error) {
+	remote := make([]map[string]interface{}, 0, len(sources))
+	for _, src := range sources {
+		settings, err := src.Read(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		remote = append(remote, settings)
+	}
+	return remote, nil
+}
+
+// load reads the layers of opts into T with the settings read from its remote sources
+func load[T any](opts LayeredOptions, remote []map[string]interface{}) (*Loaded[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	// the default tags are below the explicit defaults
+	layers := []layer{{source: SourceDefault, settings: tagDefaults(typ)}}
+	if len(opts.Defaults) > 0 {
+		layers = append(layers, layer{source: SourceDefault, settings: flatten(opts.Defaults)})
+	}
+	if opts.File != "" {
+		l, err := readFileLayer(SourceFile, opts.File, opts.Format)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+	if opts.Profile != "" {
+		if opts.File == "" {
+			return nil, fmt.Errorf("profile %s requires a base file", opts.Profile)
+		}
+		l, err := readFileLayer(SourceProfile, profileFile(opts.File, opts.Profile), opts.Format)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+	for _, settings := range remote {
+		layers = append(layers, layer{source: SourceRemote, settings: flatten(settings)})
+	}
+
+	known := resolveKeys(layers, typ)
+	if opts.EnvPrefix != "" {
+		layers = append(layers, layer{source: SourceEnv, settings: envSettings(opts.EnvPrefix, known)})
+	}
+	if opts.Flags != nil {
+		layers = append(layers, layer{source: SourceFlag, settings: flagSettings(opts.Flags, known)})
+	}
+
+	var conf T
+	sources, encrypted, err := build(layers, opts.Cryptor, &conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Loaded[T]{Config: &conf, Sources: sources, Encrypted: encrypted}, nil
+}
+
+// layer holds the flattened settings read from a source
+type layer struct {
+	source   SourceKind
+	settings map[string]interface{}
+	// dotenv is set for the dotenv layers, whose underscored keys are resolved by resolveKeys
+	dotenv bool
+}
+
+// resolveKeys maps the keys of the dotenv layers to the config keys of t,
+// then returns the keys of t and of the layers
+func resolveKeys(layers []layer, t reflect.Type) map[string]bool {
+	known := structKeys(t)
+	underscored := map[string]string{}
+	for k := range known {
+		underscored[strings.ReplaceAll(k, ".", "_")] = k
+	}
+	for i, l := range layers {
+		if !l.dotenv {
+			continue
+		}
+		settings := make(map[string]interface{}, len(l.settings))
+		for k, v := range l.settings {
+			if key, ok := underscored[k]; ok {
+				k = key
+			}
+			settings[k] = v
+		}
+		layers[i].settings, layers[i].dotenv = settings, false
+	}
+	for _, l := range layers {
+		for k := range l.settings {
+			known[k] = true
+		}
+	}
+	return known
+}
+
+// build merges the layers into conf, the encrypted values are decrypted and
+// conf is checked with its validate tags. It returns the source of every key
+// and the keys of the encrypted values.
+func build(layers []layer, c crypto.Cryptor, conf interface{}) (map[string]SourceKind, map[string]bool, error) {
+	settings, sources := merge(layers)
+	encrypted, err := decryptSettings(settings, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = decode(settings, conf); err != nil {
+		return nil, nil, err
+	}
+	if err = check(conf); err != nil {
+		return nil, nil, err
+	}
+	return sources, encrypted, nil
+}
+
+// merge merges the layers in order and records the source of every key
+func merge(layers []layer) (map[string]interface{}, map[string]SourceKind) {
+	settings := map[string]interface{}{}
+	sources := map[string]SourceKind{}
+	for _, l := range layers {
+		for k, v := range l.settings {
+			// a leaf replaces the nested keys below it and the other way round
+			for old := range settings {
+				if strings.HasPrefix(old, k+".") || strings.HasPrefix(k, old+".") {
+					delete(settings, old)
+					delete(sources, old)
+				}
+			}
+			settings[k] = v
+			sources[k] = l.source
+		}
+	}
+	return settings, sources
+}
+
+// decode unmarshals the flattened settings into conf
+func decode(settings map[string]interface{}, conf interface{}) error {
+	v := viper.New()
+	if err := v.MergeConfigMap(unflatten(settings)); err != nil {
+		return fmt.Errorf("error merging config: %w", err)
+	}
+	if err := v.Unmarshal(conf); err != nil {
+		return fmt.Errorf("error unmarshal config file: %w", err)
+	}
+	return nil
+}
+
+// profileFile returns the profile file next to file, config.yaml becomes config.prod.yaml
+func profileFile(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+// envSettings reads the env of the known keys, the keys of the defaults, the
+// config struct and the layers below. The env of a key with nested keys below
+// it is ignored, a scalar never replaces a subtree.
+func envSettings(prefix string, known map[string]bool) map[string]interface{} {
+	prefix = strings.ToUpper(strings.TrimSuffix(prefix, "_")) + "_"
+	settings := map[string]interface{}{}
+	for k := range known {
+		if hasNested(known, k) {
+			continue
+		}
+		name := prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(k))
+		if v, ok := os.LookupEnv(name); ok {
+			settings[k] = v
+		}
+	}
+	return settings
+}
+
+// hasNested reports whether keys holds a key nested below key
+func hasNested(keys map[string]bool, key string) bool {
+	for k := range keys {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// flagSettings reads the changed flags, a flag named db-host sets db.host if it is a known key
+func flagSettings(fs *pflag.FlagSet, known map[string]bool) map[string]interface{} {
+	settings := map[string]interface{}{}
+	fs.Visit(func(f *pflag.Flag) {
+		key := strings.ToLower(f.Name)
+		if dotted := strings.ReplaceAll(key, "-", "."); !known[key] && known[dotted] {
+			key = dotted
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			settings[key] = sv.GetSlice()
+		} else {
+			settings[key] = f.Value.String()
+		}
+	})
+	return settings
+}
+
+// flatten turns nested maps into dotted lower case keys
+func flatten(m map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			key := strings.ToLower(prefix + k)
+			switch nested := v.(type) {
+			case map[string]interface{}:
+				walk(key+".", nested)
+			case map[interface{}]interface{}:
+				converted := make(map[string]interface{}, len(nested))
+				for nk, nv := range nested {
+					converted[fmt.Sprint(nk)] = nv
+				}
+				walk(key+".", converted)
+			default:
+				res[key] = v
+			}
+		}
+	}
+	walk("", m)
+	return res
+}
+
+// unflatten turns dotted keys into nested maps
+func unflatten(flat map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := map[string]interface{}{}
+	for _, k := range keys {
+		parts := strings.Split(k, ".")
+		m := res
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[p] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = flat[k]
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dbConf struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	MaxConns int    `mapstructure:"max_conns"`
+	User     string `mapstructure:"user"`
+}
+
+type appConf struct {
+	Name  string   `mapstructure:"name"`
+	Debug bool     `mapstructure:"debug"`
+	Tags  []string `mapstructure:"tags"`
+	DB    dbConf   `mapstructure:"db"`
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("APP_DB_MAX_CONNS", "20")
+	t.Setenv("APP_DB_USER", "root")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("db.port", "", "")
+	fs.Bool("debug", false, "")
+	fs.StringSlice("tags", nil, "")
+	require.NoError(t, fs.Parse([]string{"--db.port=3307", "--tags=a,b"}))
+
+	loaded, err := Load[appConf](LayeredOptions{
+		Defaults:  map[string]interface{}{"debug": true, "db.user": "guest"},
+		File:      "testdata/config.yaml",
+		Profile:   "prod",
+		EnvPrefix: "APP",
+		Flags:     fs,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, appConf{
+		Name:  "kit",
+		Debug: true,
+		Tags:  []string{"a", "b"},
+		DB:    dbConf{Host: "db.prod", Port: 3307, MaxConns: 20, User: "root"},
+	}, *loaded.Config)
+
+	tests := []struct {
+		key  string
+		want SourceKind
+	}{
+		{"debug", SourceDefault},
+		{"name", SourceFile},
+		{"db.host", SourceProfile},
+		{"db.max_conns", SourceEnv},
+		{"db.user", SourceEnv},
+		{"DB.Port", SourceFlag},
+		{"unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, loaded.Source(tt.key))
+		})
+	}
+}
+
+func TestEnvSettings(t *testing.T) {
+	t.Setenv("APP_NAME", "env")
+	t.Setenv("APP_DB", "x")
+	t.Setenv("APP_DB_HOST", "db.env")
+	t.Setenv("APP_UNKNOWN_KEY", "y")
+
+	known := map[string]bool{"name": true, "db": true, "db.host": true, "db.port": true}
+	assert.Equal(t, map[string]interface{}{"name": "env", "db.host": "db.env"}, envSettings("APP", known))
+
+	loaded, err := Load[appConf](LayeredOptions{File: "testdata/config.yaml", EnvPrefix: "APP"})
+	require.NoError(t, err)
+	assert.Equal(t, "db.env", loaded.Config.DB.Host)
+	assert.Equal(t, SourceKind(""), loaded.Source("unknown.key"))
+}
+
+func TestLoad_MissingProfile(t *testing.T) {
+	_, err := Load[appConf](LayeredOptions{File: "testdata/config.yaml", Profile: "dev"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/atong007/kit/crypto"
+)
+
+// Format is the format of a config source
+type Format string
+
+// The supported formats
+const (
+	FormatYAML   Format = "yaml"
+	FormatTOML   Format = "toml"
+	FormatJSON   Format = "json"
+	FormatDotenv Format = "dotenv"
+)
+
+// FormatOf returns the format of the file extension
+func FormatOf(file string) (Format, error) {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), ".")); ext {
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	case "json":
+		return FormatJSON, nil
+	case "env", "dotenv":
+		return FormatDotenv, nil
+	default:
+		return "", fmt.Errorf("unknown config format of %s", file)
+	}
+}
+
+// Loader reads config sources into structs. Unlike the viper singleton,
+// every Loader is isolated, so several configs may be loaded side by side.
+// The sources are merged in the order they are read, the later ones override
+// the keys of the former ones. A Loader is not safe for concurrent use.
+type Loader struct {
+	layers  []layer
+	cryptor crypto.Cryptor
+}
+
+// NewLoader creates a new empty Loader
+func NewLoader() *Loader {
+	return &Loader{}
+}
+
+// SetCryptor sets the cryptor of the ENC(...) values, the AesGCM of MasterKey is used otherwise
+func (l *Loader) SetCryptor(c crypto.Cryptor) *Loader {
+	l.cryptor = c
+	return l
+}
+
+// SetDefaults adds the default values keyed by dotted key paths or nested maps, below the sources read so far
+func (l *Loader) SetDefaults(defaults map[string]interface{}) *Loader {
+	l.layers = append([]layer{{source: SourceDefault, settings: flatten(defaults)}}, l.layers...)
+	return l
+}
+
+// ReadFile reads the file, format may be empty to guess it from the extension
+func (l *Loader) ReadFile(file string, format Format) error {
+	layer, err := readFileLayer(SourceFile, file, format)
+	if err != nil {
+		return err
+	}
+	l.layers = append(l.layers, layer)
+	return nil
+}
+
+// ReadFS reads the file name of fsys, such as an embed.FS, format may be empty to guess it from the extension
+func (l *Loader) ReadFS(fsys fs.FS, name string, format Format) error {
+	if format == "" {
+		var err error
+		if format, err = FormatOf(name); err != nil {
+			return err
+		}
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("error loading config file: %w", err)
+	}
+	defer f.Close()
+	return l.Read(f, format)
+}
+
+// Read reads the config of the format from r
+func (l *Loader) Read(r io.Reader, format Format) error {
+	layer, err := readLayer(SourceFile, r, format)
+	if err != nil {
+		return err
+	}
+	l.layers = append(l.layers, layer)
+	return nil
+}
+
+// ReadBytes reads the config of the format from b
+func (l *Loader) ReadBytes(b []byte, format Format) error {
+	return l.Read(bytes.NewReader(b), format)
+}
+
+// Unmarshal merges the sources into conf, a pointer to a struct. The default
+// tags of conf are applied below the sources, the encrypted values are
+// decrypted and the result is checked with its validate tags.
+func (l *Loader) Unmarshal(conf interface{}) error {
+	t := reflect.TypeOf(conf)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return fmt.Errorf("error unmarshal config: %T is not a pointer", conf)
+	}
+	layers := make([]layer, 0, len(l.layers)+1)
+	layers = append(layers, layer{source: SourceDefault, settings: tagDefaults(t.Elem())})
+	// resolveKeys replaces the settings of the copied dotenv layers, the loader keeps its own
+	layers = append(layers, l.layers...)
+	resolveKeys(layers, t.Elem())
+	_, _, err := build(layers, l.cryptor, conf)
+	return err
+}
+
+func readFileLayer(source SourceKind, file string, format Format) (layer, error) {
+	if format == "" {
+		var err error
+		if format, err = FormatOf(file); err != nil {
+			return layer{}, err
+		}
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return layer{}, fmt.Errorf("error loading config file: %w", err)
+	}
+	defer f.Close()
+	return readLayer(source, f, format)
+}
+
+func readLayer(source SourceKind, r io.Reader, format Format) (layer, error) {
+	switch format {
+	case FormatYAML, FormatTOML, FormatJSON, FormatDotenv:
+	default:
+		return layer{}, fmt.Errorf("unsupported config format %s", format)
+	}
+	v := viper.New()
+	v.SetConfigType(string(format))
+	if err := v.ReadConfig(r); err != nil {
+		return layer{}, fmt.Errorf("error loading config file: %w", err)
+	}
+	return layer{source: source, settings: flatten(v.AllSettings()), dotenv: format == FormatDotenv}, nil
+}
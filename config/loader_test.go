@@ -0,0 +1,63 @@
+package config
+
+import (
+	"embed"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata
+var testdata embed.FS
+
+func TestLoader(t *testing.T) {
+	want := appConf{Name: "kit", DB: dbConf{Host: "localhost", Port: 3306, MaxConns: 10}}
+	tests := []struct {
+		name    string
+		format  Format
+		content string
+	}{
+		{"yaml", FormatYAML, "name: kit\ndb:\n  host: localhost\n  port: 3306\n  max_conns: 10\n"},
+		{"toml", FormatTOML, "name = \"kit\"\n[db]\nhost = \"localhost\"\nport = 3306\nmax_conns = 10\n"},
+		{"json", FormatJSON, `{"name": "kit", "db": {"host": "localhost", "port": 3306, "max_conns": 10}}`},
+		{"dotenv", FormatDotenv, "NAME=kit\nDB_HOST=localhost\nDB_PORT=3306\nDB_MAX_CONNS=10\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var conf appConf
+			l := NewLoader()
+			require.NoError(t, l.ReadBytes([]byte(tt.content), tt.format))
+			require.NoError(t, l.Unmarshal(&conf))
+			assert.Equal(t, want, conf)
+		})
+	}
+}
+
+func TestLoader_Sources(t *testing.T) {
+	l := NewLoader().SetDefaults(map[string]interface{}{"db.user": "guest"})
+	require.NoError(t, l.ReadFS(testdata, "testdata/config.yaml", ""))
+	require.NoError(t, l.ReadFile("testdata/config.prod.yaml", FormatYAML))
+	require.NoError(t, l.Read(strings.NewReader("DB_PORT=3307"), FormatDotenv))
+
+	var conf appConf
+	require.NoError(t, l.Unmarshal(&conf))
+	assert.Equal(t, appConf{Name: "kit", DB: dbConf{Host: "db.prod", Port: 3307, MaxConns: 10, User: "guest"}}, conf)
+
+	// another loader does not share the state of l
+	var other appConf
+	require.NoError(t, NewLoader().Unmarshal(&other))
+	assert.Equal(t, appConf{}, other)
+}
+
+func TestLoader_Errors(t *testing.T) {
+	l := NewLoader()
+	assert.Error(t, l.ReadBytes([]byte("name: kit"), "xml"))
+	assert.Error(t, l.ReadFile("testdata/config", ""))
+	assert.Error(t, l.ReadFile("testdata/missing.yaml", ""))
+	assert.Error(t, l.ReadBytes([]byte("name: [kit"), FormatYAML))
+	assert.Error(t, l.Unmarshal(appConf{}))
+}
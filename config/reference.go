@@ -0,0 +1,234 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldDoc documents a config key, it is read from the tags of the field:
+// mapstructure for the key, default, desc for the description, secret and validate
+type FieldDoc struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Secret      bool   `json:"secret,omitempty"`
+	// Validate is the validate tag of the field
+	Validate string `json:"validate,omitempty"`
+}
+
+// Reference returns the docs of the config keys of conf, a struct or a pointer to one
+func Reference(conf interface{}) []FieldDoc {
+	t := reflect.TypeOf(conf)
+	var docs []FieldDoc
+	if t == nil {
+		return docs
+	}
+	walkFields(t, func(f field) {
+		rules := f.Tag.Get("validate")
+		docs = append(docs, FieldDoc{
+			Key:         f.key,
+			Type:        typeName(f.Type),
+			Default:     f.Tag.Get("default"),
+			Description: f.Tag.Get("desc"),
+			Required:    hasRule(rules, "required"),
+			Secret:      isSecret(f),
+			Validate:    rules,
+		})
+	})
+	return docs
+}
+
+// Markdown renders the reference of conf as a markdown table
+func Markdown(conf interface{}) string {
+	var b strings.Builder
+	b.WriteString("| Key | Type | Default | Required | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, d := range Reference(conf) {
+		desc := d.Description
+		if d.Secret {
+			desc = strings.TrimSpace(desc + " (secret)")
+		}
+		required := ""
+		if d.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", d.Key, d.Type, code(d.Default), required, desc)
+	}
+	return b.String()
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
+
+// JSONSchema returns the JSON Schema of conf, a struct or a pointer to one
+func JSONSchema(conf interface{}) ([]byte, error) {
+	root := map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+	t := reflect.TypeOf(conf)
+	if t != nil {
+		walkFields(t, func(f field) {
+			parts := strings.Split(f.key, ".")
+			obj := root
+			for _, p := range parts[:len(parts)-1] {
+				props := obj["properties"].(map[string]interface{})
+				next, ok := props[p].(map[string]interface{})
+				if !ok {
+					next = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+					props[p] = next
+				}
+				obj = next
+			}
+			name := parts[len(parts)-1]
+			obj["properties"].(map[string]interface{})[name] = fieldSchema(f)
+			if hasRule(f.Tag.Get("validate"), "required") {
+				required, _ := obj["required"].([]string)
+				obj["required"] = append(required, name)
+			}
+		})
+	}
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding json schema: %w", err)
+	}
+	return b, nil
+}
+
+func fieldSchema(f field) map[string]interface{} {
+	s := map[string]interface{}{}
+	typ := schemaType(f.Type)
+	if typ != "" {
+		s["type"] = typ
+	}
+	if typ == "array" {
+		if items := schemaType(indirect(f.Type).Elem()); items != "" {
+			s["items"] = map[string]interface{}{"type": items}
+		}
+	}
+	if desc := f.Tag.Get("desc"); desc != "" {
+		s["description"] = desc
+	}
+	if isSecret(f) {
+		s["writeOnly"] = true
+	}
+	if def, ok := f.Tag.Lookup("default"); ok {
+		s["default"] = schemaValue(typ, def)
+	}
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "min", "gte", "max", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			lower := name == "min" || name == "gte"
+			switch {
+			case typ == "string" && lower:
+				s["minLength"] = n
+			case typ == "string":
+				s["maxLength"] = n
+			case typ == "array" && lower:
+				s["minItems"] = n
+			case typ == "array":
+				s["maxItems"] = n
+			case lower:
+				s["minimum"] = n
+			default:
+				s["maximum"] = n
+			}
+		case "oneof":
+			var enum []interface{}
+			for _, v := range strings.Fields(param) {
+				enum = append(enum, schemaValue(typ, v))
+			}
+			s["enum"] = enum
+		case "url", "uri":
+			s["format"] = "uri"
+		case "email":
+			s["format"] = "email"
+		}
+	}
+	return s
+}
+
+// schemaValue converts the tag value to the JSON type
+func schemaValue(typ, v string) interface{} {
+	switch typ {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	case "array":
+		return strings.Split(v, ",")
+	}
+	return v
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func schemaType(t reflect.Type) string {
+	t = indirect(t)
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)), t == timeType:
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return ""
+}
+
+// typeName is the name of the type in the docs
+func typeName(t reflect.Type) string {
+	t = indirect(t)
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return "duration"
+	case timeType:
+		return "time"
+	}
+	return t.String()
+}
+
+func hasRule(rules, rule string) bool {
+	for _, r := range strings.Split(rules, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
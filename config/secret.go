@@ -0,0 +1,288 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/atong007/kit/crypto"
+	"github.com/atong007/kit/crypto/aes"
+)
+
+// The env holding the hex master key of the encrypted values, or the path of a file holding it
+const (
+	MasterKeyEnv     = "KIT_CONFIG_KEY"
+	MasterKeyFileEnv = "KIT_CONFIG_KEY_FILE"
+)
+
+// ErrNoMasterKey is returned when a config has encrypted values and no master key is set
+var ErrNoMasterKey = errors.New("no master key, set " + MasterKeyEnv + " or " + MasterKeyFileEnv)
+
+const (
+	encPrefix = "ENC("
+	encSuffix = ")"
+)
+
+// IsEncrypted reports whether v is an encrypted value such as ENC(...)
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, encPrefix) && strings.HasSuffix(v, encSuffix) && len(v) > len(encPrefix+encSuffix)
+}
+
+// Encrypt encrypts the plain value into ENC(...)
+func Encrypt(c crypto.Cryptor, plain string) (string, error) {
+	enc, err := c.EncryptedCode(plain)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting value: %w", err)
+	}
+	return encPrefix + enc + encSuffix, nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt, the other values are returned as is
+func Decrypt(c crypto.Cryptor, v string) (string, error) {
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+	plain, err := c.DecryptedCode(strings.TrimSuffix(strings.TrimPrefix(v, encPrefix), encSuffix))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting value: %w", err)
+	}
+	return plain, nil
+}
+
+// MasterKey reads the master key from MasterKeyEnv, or from the file named by MasterKeyFileEnv
+func MasterKey() (string, error) {
+	if key := os.Getenv(MasterKeyEnv); key != "" {
+		return key, nil
+	}
+	if file := os.Getenv(MasterKeyFileEnv); file != "" {
+		return ReadKeyFile(file)
+	}
+	return "", ErrNoMasterKey
+}
+
+// ReadKeyFile reads a hex master key from the file
+func ReadKeyFile(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading key file: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// NewCryptor creates the AesGCM cryptor of a hex master key
+func NewCryptor(key string) (crypto.Cryptor, error) {
+	c, err := aes.NewAesGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return c, nil
+}
+
+// decryptSettings decrypts the encrypted values of the settings in place and
+// returns the keys holding them, the cryptor is only created if there is an
+// encrypted value
+func decryptSettings(settings map[string]interface{}, c crypto.Cryptor) (map[string]bool, error) {
+	encrypted := map[string]bool{}
+	decrypt := func(key, v string) (string, error) {
+		if c == nil {
+			mk, err := MasterKey()
+			if err != nil {
+				return "", fmt.Errorf("error decrypting config key %s: %w", key, err)
+			}
+			if c, err = NewCryptor(mk); err != nil {
+				return "", err
+			}
+		}
+		plain, err := Decrypt(c, v)
+		if err != nil {
+			return "", fmt.Errorf("error decrypting config key %s: %w", key, err)
+		}
+		return plain, nil
+	}
+	for k, v := range settings {
+		var found bool
+		plain, err := decryptValue(k, v, func(key, v string) (string, error) {
+			found = true
+			return decrypt(key, v)
+		})
+		if err != nil {
+			return nil, err
+		}
+		settings[k] = plain
+		if found {
+			encrypted[k] = true
+		}
+	}
+	return encrypted, nil
+}
+
+// decryptValue decrypts the encrypted strings of v, including those of the
+// slices and of the maps in them. The slices and maps are shared with the
+// layers, hence they are copied.
+func decryptValue(key string, v interface{}, decrypt func(key, v string) (string, error)) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		if !IsEncrypted(v) {
+			return v, nil
+		}
+		return decrypt(key, v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			plain, err := decryptValue(fmt.Sprintf("%s[%d]", key, i), item, decrypt)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = plain
+		}
+		return items, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			plain, err := decryptValue(key+"."+k, item, decrypt)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = plain
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, item := range v {
+			plain, err := decryptValue(fmt.Sprintf("%s.%v", key, k), item, decrypt)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = plain
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+// EncryptFile encrypts the values of the keys in the YAML config file, the encrypted values are kept.
+// A key of the items of a list encrypts the value of every item.
+func EncryptFile(file string, keys []string, c crypto.Cryptor) error {
+	wanted := map[string]bool{}
+	for _, k := range keys {
+		wanted[strings.ToLower(k)] = true
+	}
+	// the keys of lists repeat, every value is encrypted
+	found := map[string]bool{}
+	return rewriteFile(file, func(key, v string) (string, error) {
+		if !wanted[key] {
+			return v, nil
+		}
+		found[key] = true
+		if IsEncrypted(v) {
+			return v, nil
+		}
+		return Encrypt(c, v)
+	}, func() error {
+		for k := range wanted {
+			if !found[k] {
+				return fmt.Errorf("config key %s not found in %s", k, file)
+			}
+		}
+		return nil
+	})
+}
+
+// DecryptFile decrypts all the encrypted values of the YAML config file
+func DecryptFile(file string, c crypto.Cryptor) error {
+	return rewriteFile(file, func(key, v string) (string, error) {
+		plain, err := Decrypt(c, v)
+		if err != nil {
+			return "", fmt.Errorf("error decrypting config key %s: %w", key, err)
+		}
+		return plain, nil
+	}, nil)
+}
+
+// RekeyFile re-encrypts all the encrypted values of the YAML config file from the old cryptor to the new one
+func RekeyFile(file string, old, new crypto.Cryptor) error {
+	return rewriteFile(file, func(key, v string) (string, error) {
+		if !IsEncrypted(v) {
+			return v, nil
+		}
+		plain, err := Decrypt(old, v)
+		if err != nil {
+			return "", fmt.Errorf("error decrypting config key %s: %w", key, err)
+		}
+		return Encrypt(new, plain)
+	}, nil)
+}
+
+// rewriteFile rewrites the scalar values of a YAML file with fn, keeping its comments and order.
+// The file is left untouched if fn or check fails.
+func rewriteFile(file string, fn func(key, v string) (string, error), check func() error) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error loading config file: %w", err)
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("error parsing config file: %w", err)
+	}
+	if err = rewriteNode(&doc, "", fn); err != nil {
+		return err
+	}
+	if check != nil {
+		if err = check(); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return fmt.Errorf("error encoding config file: %w", err)
+	}
+	if err = enc.Close(); err != nil {
+		return fmt.Errorf("error encoding config file: %w", err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	if err = os.WriteFile(file, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
+
+func rewriteNode(n *yaml.Node, key string, fn func(key, v string) (string, error)) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := rewriteNode(c, key, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := strings.ToLower(n.Content[i].Value)
+			if key != "" {
+				k = key + "." + k
+			}
+			if err := rewriteNode(n.Content[i+1], k, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		v, err := fn(key, n.Value)
+		if err != nil {
+			return err
+		}
+		if v != n.Value {
+			// the encoder quotes the strings which would read as other types
+			n.Value, n.Tag, n.Style = v, "!!str", 0
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKey    = "1ab3adcf15eeb01bc812aae31b24efb5"
+	testNewKey = "0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+)
+
+type secretConf struct {
+	DB struct {
+		Host     string `mapstructure:"host"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"db"`
+	Mail struct {
+		Password string `mapstructure:"password"`
+	} `mapstructure:"mail"`
+}
+
+func writeConfig(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestLoadEncrypted(t *testing.T) {
+	c, err := NewCryptor(testKey)
+	require.NoError(t, err)
+	enc, err := Encrypt(c, "secret")
+	require.NoError(t, err)
+	file := writeConfig(t, "db:\n  host: localhost\n  password: "+enc+"\nmail:\n  password: plain\n")
+
+	t.Run("no master key", func(t *testing.T) {
+		t.Setenv(MasterKeyEnv, "")
+		_, err := LoadAndRead[secretConf](file)
+		assert.ErrorIs(t, err, ErrNoMasterKey)
+		assert.Contains(t, err.Error(), "db.password")
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(MasterKeyEnv, testKey)
+		conf, err := LoadAndRead[secretConf](file)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", conf.DB.Password)
+		assert.Equal(t, "plain", conf.Mail.Password)
+	})
+
+	t.Run("key file", func(t *testing.T) {
+		keyFile := filepath.Join(t.TempDir(), "key")
+		require.NoError(t, os.WriteFile(keyFile, []byte(testKey+"\n"), 0o600))
+		t.Setenv(MasterKeyEnv, "")
+		t.Setenv(MasterKeyFileEnv, keyFile)
+		conf, err := LoadAndRead[secretConf](file)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", conf.DB.Password)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		wrong, err := NewCryptor(testNewKey)
+		require.NoError(t, err)
+		_, err = Load[secretConf](LayeredOptions{File: file, Cryptor: wrong})
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadEncrypted_List(t *testing.T) {
+	type dbConf struct {
+		Host     string `mapstructure:"host"`
+		Password string `mapstructure:"password"`
+	}
+	type conf struct {
+		DBs    []dbConf `mapstructure:"dbs"`
+		Tokens []string `mapstructure:"tokens"`
+	}
+	c, err := NewCryptor(testKey)
+	require.NoError(t, err)
+	enc, err := Encrypt(c, "secret")
+	require.NoError(t, err)
+	file := writeConfig(t, "dbs:\n  - host: a\n    password: "+enc+"\n  - host: b\n    password: plain\ntokens:\n  - "+enc+"\n")
+
+	loaded, err := Load[conf](LayeredOptions{File: file, Cryptor: c})
+	require.NoError(t, err)
+	assert.Equal(t, []dbConf{{Host: "a", Password: "secret"}, {Host: "b", Password: "plain"}}, loaded.Config.DBs)
+	assert.Equal(t, []string{"secret"}, loaded.Config.Tokens)
+}
+
+func TestSecretFile(t *testing.T) {
+	c, err := NewCryptor(testKey)
+	require.NoError(t, err)
+	newC, err := NewCryptor(testNewKey)
+	require.NoError(t, err)
+	file := writeConfig(t, "# the database\ndb:\n  host: localhost\n  password: \"123456\"\nmail:\n  password: secret\n")
+
+	assert.Error(t, EncryptFile(file, []string{"db.missing"}, c))
+	require.NoError(t, EncryptFile(file, []string{"db.password", "mail.password"}, c))
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "# the database")
+	assert.NotContains(t, string(b), "123456")
+	assert.NotContains(t, string(b), "secret")
+
+	require.NoError(t, RekeyFile(file, c, newC))
+	conf, err := Load[secretConf](LayeredOptions{File: file, Cryptor: newC})
+	require.NoError(t, err)
+	assert.Equal(t, "123456", conf.Config.DB.Password)
+	assert.Equal(t, "secret", conf.Config.Mail.Password)
+
+	require.NoError(t, DecryptFile(file, newC))
+	b, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "# the database\ndb:\n  host: localhost\n  password: \"123456\"\nmail:\n  password: secret\n", string(b))
+}
+
+func TestEncryptFile_RepeatedKey(t *testing.T) {
+	c, err := NewCryptor(testKey)
+	require.NoError(t, err)
+	file := writeConfig(t, "dbs:\n  - host: a\n    password: first\n  - host: b\n    password: second\n")
+
+	require.NoError(t, EncryptFile(file, []string{"dbs.password"}, c))
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "first")
+	assert.NotContains(t, string(b), "second")
+
+	require.NoError(t, DecryptFile(file, c))
+	b, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "dbs:\n  - host: a\n    password: first\n  - host: b\n    password: second\n", string(b))
+}
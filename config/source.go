@@ -0,0 +1,338 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source is a remote config source, such as etcd or Consul KV
+type Source interface {
+	// Read returns the current settings, keyed by dotted key paths or nested maps
+	Read(ctx context.Context) (map[string]interface{}, error)
+	// Watch calls fn with the settings after every change, until ctx is done or
+	// the source fails. The changes made since the last Read may be reported.
+	Watch(ctx context.Context, fn func(settings map[string]interface{})) error
+}
+
+// HTTPSource is a Source backed by a Consul style HTTP key-value API. The keys
+// below the prefix are the config keys, app/db/host sets db.host for the
+// prefix app. The changes are watched with blocking queries, or by polling.
+type HTTPSource struct {
+	client    *http.Client
+	addr      string
+	prefix    string
+	token     string
+	wait      time.Duration
+	poll      time.Duration
+	cacheFile string
+
+	// the index and settings of the last read
+	mu    sync.Mutex
+	index uint64
+	last  map[string]interface{}
+}
+
+// HTTPSourceOption configures a HTTPSource
+type HTTPSourceOption func(s *HTTPSource)
+
+// WithHTTPClient sets the client of the requests, its timeout must exceed the blocking wait
+func WithHTTPClient(c *http.Client) HTTPSourceOption {
+	return func(s *HTTPSource) {
+		s.client = c
+	}
+}
+
+// WithToken sets the ACL token of the requests
+func WithToken(token string) HTTPSourceOption {
+	return func(s *HTTPSource) {
+		s.token = token
+	}
+}
+
+// WithBlockingWait sets the maximum duration of a blocking query, 5 minutes by default
+func WithBlockingWait(d time.Duration) HTTPSourceOption {
+	return func(s *HTTPSource) {
+		s.wait = d
+	}
+}
+
+// WithPollInterval watches the changes by polling every d instead of blocking queries
+func WithPollInterval(d time.Duration) HTTPSourceOption {
+	return func(s *HTTPSource) {
+		s.poll = d
+	}
+}
+
+// WithCacheFile keeps the last settings read in the file, they are read from it while the service is unreachable
+func WithCacheFile(file string) HTTPSourceOption {
+	return func(s *HTTPSource) {
+		s.cacheFile = file
+	}
+}
+
+// NewHTTPSource creates a new HTTPSource of the keys below the prefix, addr is such as http://127.0.0.1:8500
+func NewHTTPSource(addr, prefix string, opts ...HTTPSourceOption) *HTTPSource {
+	s := &HTTPSource{
+		addr:   strings.TrimSuffix(addr, "/"),
+		prefix: strings.Trim(prefix, "/"),
+		wait:   time.Minute * 5,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: s.wait + time.Second*30}
+	}
+	return s
+}
+
+// Read reads the keys, the cache file is read if the service is unreachable
+func (s *HTTPSource) Read(ctx context.Context) (map[string]interface{}, error) {
+	settings, index, err := s.fetch(ctx, 0)
+	if err != nil {
+		if cached, cacheErr := s.readCache(); cacheErr == nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	s.mu.Lock()
+	s.index, s.last = index, settings
+	s.mu.Unlock()
+	s.writeCache(settings)
+	return settings, nil
+}
+
+func (s *HTTPSource) Watch(ctx context.Context, fn func(settings map[string]interface{})) error {
+	s.mu.Lock()
+	index, last := s.index, s.last
+	s.mu.Unlock()
+	for {
+		var settings map[string]interface{}
+		var newIndex uint64
+		var err error
+		if s.poll > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.poll):
+			}
+			settings, newIndex, err = s.fetch(ctx, 0)
+		} else {
+			settings, newIndex, err = s.fetch(ctx, index)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if s.poll == 0 && newIndex == 0 {
+			return errors.New("no index returned by the config service, use WithPollInterval")
+		}
+
+		changed := newIndex != index
+		if s.poll > 0 {
+			changed = !reflect.DeepEqual(settings, last)
+		}
+		// the index is reset if it goes backwards, as recommended by Consul
+		if newIndex < index {
+			newIndex = 0
+		}
+		index, last = newIndex, settings
+		if changed {
+			s.mu.Lock()
+			s.index, s.last = index, settings
+			s.mu.Unlock()
+			s.writeCache(settings)
+			fn(settings)
+		}
+	}
+}
+
+// kvPair is a key of the KV API, the value is base64 encoded
+type kvPair struct {
+	Key   string
+	Value []byte
+}
+
+// fetch reads the keys, the request blocks until the keys change past index if index is not 0
+func (s *HTTPSource) fetch(ctx context.Context, index uint64) (map[string]interface{}, uint64, error) {
+	q := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", fmt.Sprintf("%ds", int(s.wait.Seconds())))
+	}
+	// the folder of the prefix, app would also match the keys of appdb
+	folder := s.prefix
+	if folder != "" {
+		folder += "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.addr+"/v1/kv/"+folder+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating config request: %w", err)
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading remote config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	settings := map[string]interface{}{}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// no key below the prefix
+		return settings, newIndex, nil
+	default:
+		return nil, 0, fmt.Errorf("error reading remote config: %s", resp.Status)
+	}
+	var pairs []kvPair
+	if err = json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, 0, fmt.Errorf("error decoding remote config: %w", err)
+	}
+	for _, p := range pairs {
+		if !strings.HasPrefix(p.Key, folder) {
+			continue
+		}
+		key := strings.TrimPrefix(p.Key, folder)
+		// the folders have no value
+		if key == "" || strings.HasSuffix(p.Key, "/") {
+			continue
+		}
+		settings[strings.ToLower(strings.ReplaceAll(key, "/", "."))] = string(p.Value)
+	}
+	return settings, newIndex, nil
+}
+
+func (s *HTTPSource) readCache() (map[string]interface{}, error) {
+	if s.cacheFile == "" {
+		return nil, os.ErrNotExist
+	}
+	b, err := os.ReadFile(s.cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]interface{}{}
+	if err = json.Unmarshal(b, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// writeCache is best effort, a stale cache only matters while the service is unreachable
+func (s *HTTPSource) writeCache(settings map[string]interface{}) {
+	if s.cacheFile == "" {
+		return
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return
+	}
+	tmp := s.cacheFile + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o600); err == nil {
+		_ = os.Rename(tmp, s.cacheFile)
+	}
+}
+
+// DirSource is a Source reading a directory, every file sets the key of its
+// path with its trimmed content, db/host sets db.host. The hidden files are
+// skipped, so it reads the kubernetes ConfigMap volumes, and the changes are
+// watched by polling. It stands in for a config service in the tests.
+type DirSource struct {
+	dir      string
+	interval time.Duration
+
+	// the settings of the last read
+	mu   sync.Mutex
+	last map[string]interface{}
+}
+
+// NewDirSource creates a new DirSource of the directory, polled every second
+func NewDirSource(dir string) *DirSource {
+	return &DirSource{dir: dir, interval: time.Second}
+}
+
+// SetInterval sets the polling interval of Watch
+func (s *DirSource) SetInterval(d time.Duration) *DirSource {
+	s.interval = d
+	return s
+}
+
+func (s *DirSource) Read(_ context.Context) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != s.dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		settings[strings.ToLower(strings.ReplaceAll(filepath.ToSlash(rel), "/", "."))] = strings.TrimSpace(string(b))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory: %w", err)
+	}
+	s.mu.Lock()
+	s.last = settings
+	s.mu.Unlock()
+	return settings, nil
+}
+
+func (s *DirSource) Watch(ctx context.Context, fn func(settings map[string]interface{})) error {
+	s.mu.Lock()
+	last := s.last
+	s.mu.Unlock()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		settings, err := s.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(settings, last) {
+			last = settings
+			fn(settings)
+		}
+	}
+}
+
+var (
+	_ Source = (*HTTPSource)(nil)
+	_ Source = (*DirSource)(nil)
+)
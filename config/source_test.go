@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kvServer is a fake Consul KV API supporting the blocking queries
+type kvServer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	index   uint64
+	kv      map[string]string
+	healthy bool
+}
+
+func newKVServer(kv map[string]string) *kvServer {
+	s := &kvServer{index: 1, kv: kv, healthy: true}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *kvServer) set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kv[key] = value
+	s.index++
+	s.cond.Broadcast()
+}
+
+func (s *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	deadline := time.Now().Add(time.Second)
+	for index > 0 && s.index <= index && time.Now().Before(deadline) {
+		// wake up periodically to honor the deadline
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			s.cond.Broadcast()
+		}()
+		s.cond.Wait()
+	}
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	var pairs []kvPair
+	for k, v := range s.kv {
+		if strings.HasPrefix(k, prefix) {
+			pairs = append(pairs, kvPair{Key: k, Value: []byte(v)})
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	_ = json.NewEncoder(w).Encode(pairs)
+}
+
+func TestHTTPSource(t *testing.T) {
+	// appdb shares the prefix app but is not below it
+	kv := newKVServer(map[string]string{"app/": "", "app/name": "kit", "app/db/port": "3306", "appdb/host": "x", "other/name": "x"})
+	srv := httptest.NewServer(kv)
+	defer srv.Close()
+	cache := filepath.Join(t.TempDir(), "cache.json")
+
+	src := NewHTTPSource(srv.URL, "app", WithCacheFile(cache))
+	loaded, err := Load[appConf](LayeredOptions{Remote: []Source{src}})
+	require.NoError(t, err)
+	assert.Equal(t, appConf{Name: "kit", DB: dbConf{Port: 3306}}, *loaded.Config)
+	assert.Equal(t, SourceRemote, loaded.Source("db.port"))
+
+	// the cache is read while the service is unavailable
+	kv.mu.Lock()
+	kv.healthy = false
+	kv.mu.Unlock()
+	conf, err := src.Read(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "kit", "db.port": "3306"}, conf)
+
+	_, err = NewHTTPSource(srv.URL, "app").Read(context.Background())
+	assert.Error(t, err)
+}
+
+func TestWatchRemote(t *testing.T) {
+	kv := newKVServer(map[string]string{"app/db/port": "3306"})
+	srv := httptest.NewServer(kv)
+	defer srv.Close()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "name"), []byte("kit\n"), 0o644))
+
+	tests := []struct {
+		name   string
+		src    Source
+		update func()
+	}{
+		{"blocking", NewHTTPSource(srv.URL, "app"), func() { kv.set("app/db/port", "3307") }},
+		{"polling", NewHTTPSource(srv.URL, "app", WithPollInterval(time.Millisecond*20)), func() { kv.set("app/db/port", "3308") }},
+		{"dir", NewDirSource(dir).SetInterval(time.Millisecond * 20), func() {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "db"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "db", "port"), []byte("3309"), 0o644))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := Watch[appConf](LayeredOptions{Remote: []Source{tt.src}}, nil)
+			require.NoError(t, err)
+			defer w.Close()
+			before := w.Get().DB.Port
+
+			changed := make(chan int, 1)
+			OnChange(w, func(c *appConf) int { return c.DB.Port }, func(_, new int) {
+				select {
+				case changed <- new:
+				default:
+				}
+			})
+			tt.update()
+			select {
+			case port := <-changed:
+				assert.NotEqual(t, before, port)
+				assert.Equal(t, port, w.Get().DB.Port)
+			case <-time.After(time.Second * 3):
+				t.Fatal("the config was not reloaded")
+			}
+		})
+	}
+}
+
+// chanSource is a Source whose changes are sent on a channel, it counts its reads
+type chanSource struct {
+	settings map[string]interface{}
+	changes  chan map[string]interface{}
+	reads    int32
+}
+
+func (s *chanSource) Read(context.Context) (map[string]interface{}, error) {
+	atomic.AddInt32(&s.reads, 1)
+	return s.settings, nil
+}
+
+func (s *chanSource) Watch(ctx context.Context, fn func(settings map[string]interface{})) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case settings := <-s.changes:
+			fn(settings)
+		}
+	}
+}
+
+func TestWatchRemote_Settings(t *testing.T) {
+	name := &chanSource{settings: map[string]interface{}{"name": "kit"}, changes: make(chan map[string]interface{})}
+	db := &chanSource{settings: map[string]interface{}{"db": map[string]interface{}{"port": 3306}}, changes: make(chan map[string]interface{})}
+	w, err := Watch[appConf](LayeredOptions{Remote: []Source{name, db}}, nil)
+	require.NoError(t, err)
+	defer w.Close()
+
+	changed := make(chan int, 1)
+	OnChange(w, func(c *appConf) int { return c.DB.Port }, func(_, new int) { changed <- new })
+	db.changes <- map[string]interface{}{"db.port": 3307}
+	select {
+	case port := <-changed:
+		assert.Equal(t, 3307, port)
+	case <-time.After(time.Second * 3):
+		t.Fatal("the config was not reloaded")
+	}
+	// the watched settings are used, the sources are not read again
+	assert.Equal(t, "kit", w.Get().Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&name.reads))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&db.reads))
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	reloadDebounce = time.Millisecond * 100
+	remoteRetry    = time.Second
+)
+
+// Watcher keeps a config loaded by Load up to date with its files.
+// A changed config is validated and swapped atomically, then the subscribers
+// are notified. A config failing to load or validate is rejected and the
+// running one is kept.
+type Watcher[T any] struct {
+	opts     LayeredOptions
+	validate func(*T) error
+	current  atomic.Value
+	reloadMu sync.Mutex
+
+	mu      sync.Mutex
+	subs    map[int]func(old, new *T)
+	nextSub int
+	onError func(error)
+
+	fsw  *fsnotify.Watcher
+	done chan struct{}
+
+	// the watch of the remote sources and their last settings, guarded by reloadMu
+	cancel         context.CancelFunc
+	remote         sync.WaitGroup
+	remoteSettings []map[string]interface{}
+}
+
+// Watch loads the config and watches its base and profile files and its remote sources, validate may be nil
+func Watch[T any](opts LayeredOptions, validate func(*T) error) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		opts:     opts,
+		validate: validate,
+		subs:     map[int]func(old, new *T){},
+		done:     make(chan struct{}),
+	}
+	remote, err := readRemote(opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := w.load(remote)
+	if err != nil {
+		return nil, err
+	}
+	w.current.Store(loaded)
+	w.remoteSettings = remote
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	for i, src := range opts.Remote {
+		w.remote.Add(1)
+		go w.watchRemote(ctx, i, src)
+	}
+
+	if opts.File == "" {
+		close(w.done)
+		return w, nil
+	}
+	if w.fsw, err = fsnotify.NewWatcher(); err != nil {
+		w.stopRemote()
+		return nil, fmt.Errorf("error watching config: %w", err)
+	}
+	files := map[string]bool{filepath.Clean(opts.File): true}
+	if opts.Profile != "" {
+		files[filepath.Clean(profileFile(opts.File, opts.Profile))] = true
+	}
+	// the directories are watched, since editors and kubernetes replace the files
+	dirs := map[string]bool{}
+	for f := range files {
+		dirs[filepath.Dir(f)] = true
+	}
+	for dir := range dirs {
+		if err = w.fsw.Add(dir); err != nil {
+			w.fsw.Close()
+			w.stopRemote()
+			return nil, fmt.Errorf("error watching config: %w", err)
+		}
+	}
+	go w.run(files)
+	return w, nil
+}
+
+// Get returns the current config
+func (w *Watcher[T]) Get() *T {
+	return w.Loaded().Config
+}
+
+// Loaded returns the current config and the sources of its keys
+func (w *Watcher[T]) Loaded() *Loaded[T] {
+	return w.current.Load().(*Loaded[T])
+}
+
+// Handler serves the current config with the secrets redacted, see Handler
+func (w *Watcher[T]) Handler() http.Handler {
+	return Handler(func() interface{} { return w.Loaded() })
+}
+
+// Subscribe registers fn to be called with the old and new config after every change
+func (w *Watcher[T]) Subscribe(fn func(old, new *T)) (unsubscribe func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextSub
+	w.nextSub++
+	w.subs[id] = fn
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// OnError registers fn to be called with the errors of the rejected changes
+func (w *Watcher[T]) OnError(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = fn
+}
+
+// Reload loads and validates the config, then swaps it and notifies the subscribers
+func (w *Watcher[T]) Reload() error {
+	remote, err := readRemote(w.opts.Remote)
+	if err != nil {
+		return err
+	}
+	return w.reload(func(settings []map[string]interface{}) {
+		copy(settings, remote)
+	})
+}
+
+// reload reloads the config with the last settings of the remote sources, as changed by update if not nil
+func (w *Watcher[T]) reload(update func(remote []map[string]interface{})) error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	remote := append([]map[string]interface{}(nil), w.remoteSettings...)
+	if update != nil {
+		update(remote)
+	}
+	loaded, err := w.load(remote)
+	if err != nil {
+		return err
+	}
+	w.remoteSettings = remote
+	old := w.Loaded()
+	w.current.Store(loaded)
+
+	w.mu.Lock()
+	ids := make([]int, 0, len(w.subs))
+	for id := range w.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	subs := make([]func(old, new *T), 0, len(ids))
+	for _, id := range ids {
+		subs = append(subs, w.subs[id])
+	}
+	w.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(old.Config, loaded.Config)
+	}
+	return nil
+}
+
+// Close stops watching the files and the remote sources
+func (w *Watcher[T]) Close() error {
+	w.stopRemote()
+	if w.fsw == nil {
+		return nil
+	}
+	err := w.fsw.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher[T]) load(remote []map[string]interface{}) (*Loaded[T], error) {
+	loaded, err := load[T](w.opts, remote)
+	if err != nil {
+		return nil, err
+	}
+	if w.validate != nil {
+		if err = w.validate(loaded.Config); err != nil {
+			return nil, fmt.Errorf("error validating config: %w", err)
+		}
+	}
+	return loaded, nil
+}
+
+func (w *Watcher[T]) run(files map[string]bool) {
+	defer close(w.done)
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if !files[filepath.Clean(ev.Name)] && !isSymlinkSwap(ev) {
+				continue
+			}
+			// coalesce the bursts of events of a single write
+			if timer == nil {
+				timer = time.NewTimer(reloadDebounce)
+			} else {
+				timer.Reset(reloadDebounce)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			// the remote sources are up to date with their watches
+			if err := w.reload(nil); err != nil {
+				w.reportError(err)
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.reportError(err)
+		}
+	}
+}
+
+// watchRemote reloads the config with the settings of every change of the
+// i-th remote source, the watch is restarted after the failures
+func (w *Watcher[T]) watchRemote(ctx context.Context, i int, src Source) {
+	defer w.remote.Done()
+	for {
+		err := src.Watch(ctx, func(settings map[string]interface{}) {
+			err := w.reload(func(remote []map[string]interface{}) {
+				remote[i] = settings
+			})
+			if err != nil {
+				w.reportError(err)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		w.reportError(fmt.Errorf("error watching remote config: %w", err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(remoteRetry):
+		}
+	}
+}
+
+func (w *Watcher[T]) stopRemote() {
+	w.cancel()
+	w.remote.Wait()
+}
+
+func (w *Watcher[T]) reportError(err error) {
+	w.mu.Lock()
+	fn := w.onError
+	w.mu.Unlock()
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// isSymlinkSwap reports whether ev is the swap of the ..data symlink of a kubernetes ConfigMap volume
+func isSymlinkSwap(ev fsnotify.Event) bool {
+	return filepath.Base(ev.Name) == "..data" && ev.Op&fsnotify.Create != 0
+}
+
+// OnChange subscribes fn to the changes of the part of the config picked by selector
+func OnChange[T any, V any](w *Watcher[T], selector func(*T) V, fn func(old, new V)) (unsubscribe func()) {
+	return w.Subscribe(func(old, new *T) {
+		o, n := selector(old), selector(new)
+		if !reflect.DeepEqual(o, n) {
+			fn(o, n)
+		}
+	})
+}
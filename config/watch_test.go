@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("name: kit\ndb:\n  port: 3306\n"), 0o644))
+
+	w, err := Watch[appConf](LayeredOptions{File: file}, func(c *appConf) error {
+		if c.DB.Port <= 0 {
+			return errors.New("invalid port")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, 3306, w.Get().DB.Port)
+
+	var mu sync.Mutex
+	var changes [][2]int
+	var errs []error
+	OnChange(w, func(c *appConf) int { return c.DB.Port }, func(old, new int) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, [2]int{old, new})
+	})
+	w.OnError(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+
+	require.NoError(t, os.WriteFile(file, []byte("name: kit\ndb:\n  port: 3307\n"), 0o644))
+	assert.Eventually(t, func() bool { return w.Get().DB.Port == 3307 }, time.Second*3, time.Millisecond*20)
+
+	require.NoError(t, os.WriteFile(file, []byte("name: kit\ndb:\n  port: -1\n"), 0o644))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	}, time.Second*3, time.Millisecond*20)
+	assert.Equal(t, 3307, w.Get().DB.Port, "an invalid config must be rejected")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][2]int{{3306, 3307}}, changes)
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	w, err := Watch[appConf](LayeredOptions{Defaults: map[string]interface{}{"name": "kit"}}, nil)
+	require.NoError(t, err)
+	called := false
+	unsubscribe := w.Subscribe(func(old, new *appConf) { called = true })
+	unsubscribe()
+	require.NoError(t, w.Reload())
+	assert.False(t, called)
+	assert.Equal(t, "kit", w.Get().Name)
+	assert.NoError(t, w.Close())
+}
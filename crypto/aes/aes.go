@@ -93,6 +93,11 @@ func (a *AesGCM) DecryptedCode(enCode string) (code string, err error) {
 		encKeySized = a.encKey[:16]
 	}
 
+	if len(input) == 0 {
+		err = errors.New("the encrypted code is empty")
+		return
+	}
+
 	i := 0
 	nonceLen := int(input[i])
 	i++
@@ -102,6 +107,10 @@ func (a *AesGCM) DecryptedCode(enCode string) (code string, err error) {
 		err = errors.New("nonce length is not correct")
 		return
 	}
+	if len(input) < 1+2*nonceLen {
+		err = errors.New("the encrypted code is too short")
+		return
+	}
 
 	iv := make([]byte, nonceLen)
 	copyWithRange(input, i, iv, 0, nonceLen)
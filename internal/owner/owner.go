@@ -0,0 +1,16 @@
+// Package owner identifies the holders of locks and leases shared across processes
+package owner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// New returns an identity unique to a single acquisition, made of the host,
+// the process id and a random uuid so that the holder can be traced back
+func New() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String())
+}
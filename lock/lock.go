@@ -0,0 +1,138 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Different types of error returned by the lockers
+var (
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	ErrLockLost    = errors.New("lock lease has been lost")
+)
+
+// minUnlockTimeout is the minimum timeout of the unlock of Do, the short ttls would expire the unlock
+const minUnlockTimeout = time.Second * 5
+
+// Locker is an interface for acquiring named distributed locks
+type Locker interface {
+	// TryLock makes a single attempt to acquire the lock
+	TryLock(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+
+	// Lock blocks until the lock is acquired or the context is done
+	Lock(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+}
+
+// Lease is a held lock
+type Lease interface {
+	// Name returns the name of the lock
+	Name() string
+
+	// Token returns the fencing token of the lease, it grows with every acquisition
+	Token() int64
+
+	// Renew extends the lease by its ttl, ErrLockLost is returned if the lease has expired
+	Renew(ctx context.Context) error
+
+	// Unlock releases the lease
+	Unlock(ctx context.Context) error
+}
+
+// KeepAlive renews the lease every interval until the context is done.
+// The returned channel receives the renewal error, if any, and is then closed.
+func KeepAlive(ctx context.Context, lease Lease, interval time.Duration) <-chan error {
+	errCh := make(chan error, 1)
+	if interval <= 0 {
+		errCh <- fmt.Errorf("invalid keep alive interval %v", interval)
+		close(errCh)
+		return errCh
+	}
+	go func() {
+		defer close(errCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lease.Renew(ctx); err != nil {
+					if ctx.Err() == nil {
+						errCh <- err
+					}
+					return
+				}
+			}
+		}
+	}()
+	return errCh
+}
+
+// Do runs fn while holding the lock. The lease is renewed in the background and
+// the context passed to fn is cancelled if the lease is lost. The lease is not
+// renewed if ttl is 0, which fits the SessionLocker.
+func Do(ctx context.Context, locker Locker, name string, ttl time.Duration, fn func(ctx context.Context, token int64) error) error {
+	if ttl < 0 {
+		return fmt.Errorf("invalid ttl %v of lock %s", ttl, name)
+	}
+	lease, err := locker.Lock(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var keepAliveErr error
+	done := make(chan struct{})
+	if interval := ttl / 3; interval > 0 {
+		errCh := KeepAlive(runCtx, lease, interval)
+		go func() {
+			defer close(done)
+			if err := <-errCh; err != nil {
+				keepAliveErr = err
+				cancel()
+			}
+		}()
+	} else {
+		close(done)
+	}
+
+	err = fn(runCtx, lease.Token())
+	cancel()
+	<-done
+
+	unlockTimeout := ttl
+	if unlockTimeout < minUnlockTimeout {
+		unlockTimeout = minUnlockTimeout
+	}
+	unlockCtx, unlockCancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer unlockCancel()
+	if uErr := lease.Unlock(unlockCtx); uErr != nil && err == nil && keepAliveErr == nil {
+		err = fmt.Errorf("error unlocking %s: %w", name, uErr)
+	}
+	if err == nil && keepAliveErr != nil {
+		err = fmt.Errorf("error renewing lock %s: %w", name, keepAliveErr)
+	}
+	return err
+}
+
+// wait retries try every interval until it succeeds, fails with an error other
+// than ErrNotAcquired, or the context is done
+func wait(ctx context.Context, interval time.Duration, try func() (Lease, error)) (Lease, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		lease, err := try()
+		if !errors.Is(err, ErrNotAcquired) {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
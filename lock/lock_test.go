@@ -0,0 +1,120 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memLease struct {
+	locker *memLocker
+	name   string
+	token  int64
+}
+
+func (m *memLease) Name() string { return m.name }
+
+func (m *memLease) Token() int64 { return m.token }
+
+func (m *memLease) Renew(ctx context.Context) error {
+	m.locker.mu.Lock()
+	defer m.locker.mu.Unlock()
+	if m.locker.lost || m.locker.held[m.name] != m.token {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (m *memLease) Unlock(ctx context.Context) error {
+	m.locker.mu.Lock()
+	defer m.locker.mu.Unlock()
+	delete(m.locker.held, m.name)
+	return nil
+}
+
+type memLocker struct {
+	mu    sync.Mutex
+	held  map[string]int64
+	token int64
+	lost  bool
+}
+
+func (m *memLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.held[name]; ok {
+		return nil, ErrNotAcquired
+	}
+	m.token++
+	m.held[name] = m.token
+	return &memLease{locker: m, name: name, token: m.token}, nil
+}
+
+func (m *memLocker) Lock(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	return wait(ctx, time.Millisecond, func() (Lease, error) {
+		return m.TryLock(ctx, name, ttl)
+	})
+}
+
+func TestLock_ContextCancel(t *testing.T) {
+	l := &memLocker{held: map[string]int64{}}
+	_, err := l.TryLock(context.Background(), "job", time.Second)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = l.Lock(ctx, "job", time.Second)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestDo(t *testing.T) {
+	l := &memLocker{held: map[string]int64{}}
+	var got int64
+	err := Do(context.Background(), l, "job", time.Millisecond*30, func(ctx context.Context, token int64) error {
+		got = token
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got)
+	assert.Empty(t, l.held)
+}
+
+func TestDo_LeaseLost(t *testing.T) {
+	l := &memLocker{held: map[string]int64{}}
+	err := Do(context.Background(), l, "job", time.Millisecond*30, func(ctx context.Context, token int64) error {
+		l.mu.Lock()
+		l.lost = true
+		l.mu.Unlock()
+		<-ctx.Done()
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrLockLost))
+}
+
+func TestDo_NoRenewal(t *testing.T) {
+	l := &memLocker{held: map[string]int64{}}
+	for _, ttl := range []time.Duration{0, time.Nanosecond} {
+		err := Do(context.Background(), l, "job", ttl, func(ctx context.Context, token int64) error {
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, l.held)
+	}
+
+	err := Do(context.Background(), l, "job", -time.Second, func(ctx context.Context, token int64) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func TestKeepAlive_InvalidInterval(t *testing.T) {
+	l := &memLocker{held: map[string]int64{}}
+	lease, err := l.TryLock(context.Background(), "job", time.Second)
+	require.NoError(t, err)
+	assert.Error(t, <-KeepAlive(context.Background(), lease, 0))
+}
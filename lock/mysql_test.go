@@ -0,0 +1,112 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/atong007/kit/sql/sqltest"
+)
+
+// openMySQL opens the MySQL of sqltest.MySQLDSNEnv, the test is skipped if it is not set
+func openMySQL(t *testing.T) *gorm.DB {
+	if os.Getenv(sqltest.MySQLDSNEnv) == "" {
+		t.Skipf("%s is not set", sqltest.MySQLDSNEnv)
+	}
+	return sqltest.Open(t)
+}
+
+// lockName returns a name unique to the run, the lock rows are kept across the runs
+func lockName(t *testing.T) string {
+	return t.Name() + "-" + uuid.New().String()
+}
+
+func TestTableLocker(t *testing.T) {
+	db := openMySQL(t)
+	l := NewTableLocker(db).SetRetryInterval(time.Millisecond * 10)
+	require.NoError(t, l.Migrate())
+	ctx := context.Background()
+	name := lockName(t)
+
+	lease, err := l.TryLock(ctx, name, time.Second*10)
+	require.NoError(t, err)
+	assert.Equal(t, name, lease.Name())
+	assert.Equal(t, int64(1), lease.Token())
+
+	_, err = l.TryLock(ctx, name, time.Second*10)
+	assert.True(t, errors.Is(err, ErrNotAcquired))
+	require.NoError(t, lease.Renew(ctx))
+	require.NoError(t, lease.Unlock(ctx))
+	assert.True(t, errors.Is(lease.Renew(ctx), ErrLockLost))
+
+	// the token keeps growing after an unlock
+	lease, err = l.TryLock(ctx, name, time.Second*10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lease.Token())
+	require.NoError(t, lease.Unlock(ctx))
+
+	_, err = l.TryLock(ctx, name, 0)
+	assert.Error(t, err)
+}
+
+func TestTableLocker_Takeover(t *testing.T) {
+	db := openMySQL(t)
+	l := NewTableLocker(db).SetRetryInterval(time.Millisecond * 10)
+	require.NoError(t, l.Migrate())
+	ctx := context.Background()
+	name := lockName(t)
+
+	expired, err := l.TryLock(ctx, name, time.Millisecond*50)
+	require.NoError(t, err)
+
+	// Lock waits for the lease to expire, then takes it over
+	lockCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	lease, err := l.Lock(lockCtx, name, time.Second*10)
+	require.NoError(t, err)
+	assert.Equal(t, expired.Token()+1, lease.Token())
+
+	// the fenced out lease can neither be renewed nor release the new one
+	assert.True(t, errors.Is(expired.Renew(ctx), ErrLockLost))
+	assert.True(t, errors.Is(expired.Unlock(ctx), ErrLockLost))
+	require.NoError(t, lease.Renew(ctx))
+	require.NoError(t, lease.Unlock(ctx))
+}
+
+func TestSessionLocker(t *testing.T) {
+	db := openMySQL(t)
+	l := NewSessionLocker(db).SetRetryInterval(time.Millisecond * 10)
+	ctx := context.Background()
+	name := lockName(t)
+
+	lease, err := l.TryLock(ctx, name, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), lease.Token())
+
+	// the lock is held by the connection of the lease
+	_, err = l.TryLock(ctx, name, 0)
+	assert.True(t, errors.Is(err, ErrNotAcquired))
+	require.NoError(t, lease.Renew(ctx))
+	require.NoError(t, lease.Unlock(ctx))
+
+	var ran bool
+	err = Do(ctx, l, name, 0, func(ctx context.Context, token int64) error {
+		ran = true
+		_, err := l.TryLock(ctx, name, 0)
+		assert.True(t, errors.Is(err, ErrNotAcquired))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	lease, err = l.TryLock(ctx, name, 0)
+	require.NoError(t, err)
+	require.NoError(t, lease.Unlock(ctx))
+}
@@ -0,0 +1,96 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionLocker is a Locker backed by MySQL GET_LOCK/RELEASE_LOCK.
+// The lock is bound to a dedicated connection and is held until it is
+// unlocked or the connection is closed, the ttl is ignored and Token is always 0.
+// It is used with Do and a ttl of 0, the lease needs no renewal.
+type SessionLocker struct {
+	db            *gorm.DB
+	retryInterval time.Duration
+}
+
+// NewSessionLocker creates a new SessionLocker with the db returned by sql.NewMySQL
+func NewSessionLocker(db *gorm.DB) *SessionLocker {
+	return &SessionLocker{db: db, retryInterval: defaultRetryInterval}
+}
+
+// SetRetryInterval sets the interval between two attempts of Lock
+func (l *SessionLocker) SetRetryInterval(d time.Duration) *SessionLocker {
+	l.retryInterval = d
+	return l
+}
+
+// TryLock makes a single attempt to acquire the lock
+func (l *SessionLocker) TryLock(ctx context.Context, name string, _ time.Duration) (Lease, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting connection: %w", err)
+	}
+
+	var got sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&got); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error acquiring lock %s: %w", name, err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		conn.Close()
+		return nil, ErrNotAcquired
+	}
+	return &sessionLease{conn: conn, name: name}, nil
+}
+
+// Lock blocks until the lock is acquired or the context is done
+func (l *SessionLocker) Lock(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	return wait(ctx, l.retryInterval, func() (Lease, error) {
+		return l.TryLock(ctx, name, ttl)
+	})
+}
+
+type sessionLease struct {
+	conn *sql.Conn
+	name string
+}
+
+func (s *sessionLease) Name() string {
+	return s.name
+}
+
+func (s *sessionLease) Token() int64 {
+	return 0
+}
+
+func (s *sessionLease) Renew(ctx context.Context) error {
+	var held sql.NullBool
+	err := s.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", s.name).Scan(&held)
+	if err != nil || !held.Valid || !held.Bool {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (s *sessionLease) Unlock(ctx context.Context) error {
+	defer s.conn.Close()
+	var released sql.NullInt64
+	if err := s.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", s.name).Scan(&released); err != nil {
+		return fmt.Errorf("error releasing lock %s: %w", s.name, err)
+	}
+	if !released.Valid || released.Int64 != 1 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+var _ Locker = (*SessionLocker)(nil)
@@ -0,0 +1,133 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/atong007/kit/internal/owner"
+	"gorm.io/gorm"
+)
+
+const defaultRetryInterval = time.Millisecond * 200
+
+// distributedLock is a row of the lease table
+type distributedLock struct {
+	Name      string    `gorm:"primaryKey;size:191"`
+	Owner     string    `gorm:"size:191;not null"`
+	Token     int64     `gorm:"not null"`
+	ExpiredAt time.Time `gorm:"type:datetime(3);not null"`
+}
+
+func (distributedLock) TableName() string {
+	return "distributed_lock"
+}
+
+// TableLocker is a Locker backed by a MySQL lease table.
+// Every acquisition increases the fencing token of the lock, expired leases
+// can be taken over by other owners.
+type TableLocker struct {
+	db            *gorm.DB
+	retryInterval time.Duration
+}
+
+// NewTableLocker creates a new TableLocker with the db returned by sql.NewMySQL
+func NewTableLocker(db *gorm.DB) *TableLocker {
+	return &TableLocker{db: db, retryInterval: defaultRetryInterval}
+}
+
+// SetRetryInterval sets the interval between two attempts of Lock
+func (l *TableLocker) SetRetryInterval(d time.Duration) *TableLocker {
+	l.retryInterval = d
+	return l
+}
+
+// Migrate creates the lease table
+func (l *TableLocker) Migrate() error {
+	return l.db.AutoMigrate(&distributedLock{})
+}
+
+// TryLock makes a single attempt to acquire the lock
+func (l *TableLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl %v of lock %s", ttl, name)
+	}
+	holder := owner.New()
+	// the assignments are evaluated from left to right, expired_at must be the last one
+	err := l.db.WithContext(ctx).Exec(
+		"INSERT INTO distributed_lock (name, owner, token, expired_at) VALUES (?, ?, 1, NOW(3) + INTERVAL ? MICROSECOND) "+
+			"ON DUPLICATE KEY UPDATE "+
+			"token = IF(expired_at < NOW(3), token + 1, token), "+
+			"owner = IF(expired_at < NOW(3), VALUES(owner), owner), "+
+			"expired_at = IF(expired_at < NOW(3), VALUES(expired_at), expired_at)",
+		name, holder, ttl.Microseconds(),
+	).Error
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring lock %s: %w", name, err)
+	}
+
+	var rec distributedLock
+	if err = l.db.WithContext(ctx).Where("name = ?", name).Take(&rec).Error; err != nil {
+		return nil, fmt.Errorf("error reading lock %s: %w", name, err)
+	}
+	if rec.Owner != holder {
+		return nil, ErrNotAcquired
+	}
+	return &tableLease{db: l.db, name: name, owner: holder, token: rec.Token, ttl: ttl}, nil
+}
+
+// Lock blocks until the lock is acquired or the context is done
+func (l *TableLocker) Lock(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	return wait(ctx, l.retryInterval, func() (Lease, error) {
+		return l.TryLock(ctx, name, ttl)
+	})
+}
+
+type tableLease struct {
+	db    *gorm.DB
+	name  string
+	owner string
+	token int64
+	ttl   time.Duration
+}
+
+func (t *tableLease) Name() string {
+	return t.name
+}
+
+func (t *tableLease) Token() int64 {
+	return t.token
+}
+
+func (t *tableLease) Renew(ctx context.Context) error {
+	res := t.db.WithContext(ctx).Exec(
+		"UPDATE distributed_lock SET expired_at = NOW(3) + INTERVAL ? MICROSECOND "+
+			"WHERE name = ? AND owner = ? AND token = ? AND expired_at >= NOW(3)",
+		t.ttl.Microseconds(), t.name, t.owner, t.token,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("error renewing lock %s: %w", t.name, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (t *tableLease) Unlock(ctx context.Context) error {
+	// the row is kept so that the fencing token keeps growing
+	res := t.db.WithContext(ctx).Exec(
+		"UPDATE distributed_lock SET expired_at = NOW(3) - INTERVAL 1 SECOND "+
+			"WHERE name = ? AND owner = ? AND token = ?",
+		t.name, t.owner, t.token,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("error releasing lock %s: %w", t.name, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+var _ Locker = (*TableLocker)(nil)
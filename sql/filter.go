@@ -0,0 +1,228 @@
+package sql
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxListLimit is the largest limit of a list query
+const MaxListLimit = 1000
+
+// DefaultListLimit is the limit of the list queries without one, it may be changed at startup
+var DefaultListLimit = 20
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Op is a filter operator
+type Op string
+
+// Supported filter operators
+const (
+	OpEq   Op = "eq"
+	OpNe   Op = "ne"
+	OpGt   Op = "gt"
+	OpGte  Op = "gte"
+	OpLt   Op = "lt"
+	OpLte  Op = "lte"
+	OpLike Op = "like"
+	OpIn   Op = "in"
+)
+
+// Filter is a condition on a single column
+type Filter struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+func Eq(field string, value interface{}) Filter     { return Filter{field, OpEq, value} }
+func Ne(field string, value interface{}) Filter     { return Filter{field, OpNe, value} }
+func Gt(field string, value interface{}) Filter     { return Filter{field, OpGt, value} }
+func Gte(field string, value interface{}) Filter    { return Filter{field, OpGte, value} }
+func Lt(field string, value interface{}) Filter     { return Filter{field, OpLt, value} }
+func Lte(field string, value interface{}) Filter    { return Filter{field, OpLte, value} }
+func Like(field string, value string) Filter        { return Filter{field, OpLike, value} }
+func In(field string, values ...interface{}) Filter { return Filter{field, OpIn, values} }
+
+func (f Filter) expression() (clause.Expression, error) {
+	col := clause.Column{Name: f.Field}
+	switch f.Op {
+	case OpEq:
+		return clause.Eq{Column: col, Value: f.Value}, nil
+	case OpNe:
+		return clause.Neq{Column: col, Value: f.Value}, nil
+	case OpGt:
+		return clause.Gt{Column: col, Value: f.Value}, nil
+	case OpGte:
+		return clause.Gte{Column: col, Value: f.Value}, nil
+	case OpLt:
+		return clause.Lt{Column: col, Value: f.Value}, nil
+	case OpLte:
+		return clause.Lte{Column: col, Value: f.Value}, nil
+	case OpLike:
+		return clause.Like{Column: col, Value: f.Value}, nil
+	case OpIn:
+		values, ok := f.Value.([]interface{})
+		if !ok {
+			values = []interface{}{f.Value}
+		}
+		return clause.IN{Column: col, Values: values}, nil
+	}
+	return nil, fmt.Errorf("unsupported filter operator %q", f.Op)
+}
+
+// Sort is an ordering on a single column
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+func Asc(field string) Sort  { return Sort{Field: field} }
+func Desc(field string) Sort { return Sort{Field: field, Desc: true} }
+
+// Query describes the filters, ordering and paging of a list query.
+// A Limit of 0 is DefaultListLimit, and it is capped at MaxListLimit.
+type Query struct {
+	Filters []Filter
+	Sorts   []Sort
+	Limit   int
+	Offset  int
+}
+
+func (q Query) apply(db *gorm.DB) (*gorm.DB, error) {
+	db, err := applyFilters(db, q.Filters)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range q.Sorts {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Field}, Desc: s.Desc})
+	}
+	db = db.Limit(q.limit())
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	return db, nil
+}
+
+// limit returns the limit of the query, defaulted and capped
+func (q Query) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultListLimit
+	case q.Limit > MaxListLimit:
+		return MaxListLimit
+	}
+	return q.Limit
+}
+
+func applyFilters(db *gorm.DB, filters []Filter) (*gorm.DB, error) {
+	for _, f := range filters {
+		expr, err := f.expression()
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(expr)
+	}
+	return db, nil
+}
+
+// ParseQuery parses filters, sorts and paging from query string values.
+// fields maps the query parameter names that may be filtered or sorted on to
+// their columns, other parameters are ignored. A missing or 0 limit is
+// DefaultListLimit, a limit above MaxListLimit is capped.
+//
+//	name=bob&age[gte]=18&status[in]=1,2&sort=-created_at,name&limit=20&offset=40
+func ParseQuery(values url.Values, fields map[string]string) (Query, error) {
+	q := Query{Limit: DefaultListLimit}
+	for key, vs := range values {
+		switch key {
+		case "sort":
+			for _, v := range vs {
+				sorts, err := parseSorts(v, fields)
+				if err != nil {
+					return Query{}, err
+				}
+				q.Sorts = append(q.Sorts, sorts...)
+			}
+			continue
+		case "limit":
+			limit, err := parseNonNegative(key, vs[0])
+			if err != nil {
+				return Query{}, err
+			}
+			q.Limit = Query{Limit: limit}.limit()
+			continue
+		case "offset":
+			offset, err := parseNonNegative(key, vs[0])
+			if err != nil {
+				return Query{}, err
+			}
+			q.Offset = offset
+			continue
+		}
+
+		name, op := key, OpEq
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:i], Op(key[i+1:len(key)-1])
+		}
+		column, ok := fields[name]
+		if !ok {
+			continue
+		}
+		for _, v := range vs {
+			f, err := newFilter(column, op, v)
+			if err != nil {
+				return Query{}, err
+			}
+			q.Filters = append(q.Filters, f)
+		}
+	}
+	return q, nil
+}
+
+func newFilter(column string, op Op, value string) (Filter, error) {
+	switch op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		return Filter{column, op, value}, nil
+	case OpLike:
+		return Like(column, "%"+likeEscaper.Replace(value)+"%"), nil
+	case OpIn:
+		var values []interface{}
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, v)
+		}
+		return In(column, values...), nil
+	}
+	return Filter{}, fmt.Errorf("unsupported filter operator %q", op)
+}
+
+func parseSorts(s string, fields map[string]string) ([]Sort, error) {
+	var sorts []Sort
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimLeft(name, "+-")
+		column, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("sorting on %q is not allowed", name)
+		}
+		sorts = append(sorts, Sort{Field: column, Desc: desc})
+	}
+	return sorts, nil
+}
+
+func parseNonNegative(key, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the buckets used by NewQueryHistogram
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	time.Millisecond * 5,
+	time.Millisecond * 10,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 500,
+	time.Second,
+	time.Second * 5,
+}
+
+// HistogramSnapshot is the state of the histogram of an operation.
+// Counts[i] is the number of statements not longer than Buckets[i], the last
+// count holds the statements longer than every bucket.
+type HistogramSnapshot struct {
+	Buckets []time.Duration
+	Counts  []uint64
+	Count   uint64
+	Sum     time.Duration
+	Errors  uint64
+}
+
+// QueryHistogram is a QueryRecorder keeping a duration histogram per table and operation
+type QueryHistogram struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	series  map[string]*HistogramSnapshot
+}
+
+// NewQueryHistogram creates a new QueryHistogram, DefaultBuckets are used if no bucket is given
+func NewQueryHistogram(buckets ...time.Duration) *QueryHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bs := append([]time.Duration(nil), buckets...)
+	sort.Slice(bs, func(i, j int) bool { return bs[i] < bs[j] })
+	return &QueryHistogram{buckets: bs, series: map[string]*HistogramSnapshot{}}
+}
+
+func (h *QueryHistogram) RecordQuery(_ context.Context, info QueryInfo) {
+	key := info.Table + ":" + info.Operation
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &HistogramSnapshot{Buckets: h.buckets, Counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	i := sort.Search(len(h.buckets), func(i int) bool { return info.Duration <= h.buckets[i] })
+	s.Counts[i]++
+	s.Count++
+	s.Sum += info.Duration
+	if info.Err != nil {
+		s.Errors++
+	}
+}
+
+// Snapshot returns a copy of the histograms keyed by "table:operation"
+func (h *QueryHistogram) Snapshot() map[string]HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	res := make(map[string]HistogramSnapshot, len(h.series))
+	for k, s := range h.series {
+		c := *s
+		c.Counts = append([]uint64(nil), s.Counts...)
+		res[k] = c
+	}
+	return res
+}
+
+var _ QueryRecorder = (*QueryHistogram)(nil)
@@ -0,0 +1,153 @@
+package sql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultBatchSize = 100
+
+// Repository provides the common CRUD operations for the model T
+type Repository[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new Repository with the db returned by NewMySQL
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// DB returns the db of the repository bound to the context, for the queries not covered by Repository
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Model(new(T))
+}
+
+// Create inserts a single record
+func (r *Repository[T]) Create(ctx context.Context, m *T) error {
+	return r.db.WithContext(ctx).Create(m).Error
+}
+
+// CreateInBatches inserts the records in batches of batchSize, a batchSize <= 0 uses the default size
+func (r *Repository[T]) CreateInBatches(ctx context.Context, ms []T, batchSize int) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return r.db.WithContext(ctx).CreateInBatches(ms, batchSize).Error
+}
+
+// Upsert inserts the records, updating the columns on duplicate key.
+// All columns are updated if no column is given.
+func (r *Repository[T]) Upsert(ctx context.Context, ms []T, columns ...string) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(onDuplicateKeyUpdate(columns)).CreateInBatches(ms, defaultBatchSize).Error
+}
+
+func onDuplicateKeyUpdate(columns []string) clause.OnConflict {
+	if len(columns) == 0 {
+		return clause.OnConflict{UpdateAll: true}
+	}
+	return clause.OnConflict{DoUpdates: clause.AssignmentColumns(columns)}
+}
+
+// byID is the condition on the primary key, the id is always bound as a value, never read as SQL
+func byID(id interface{}) clause.Eq {
+	return clause.Eq{Column: clause.PrimaryColumn, Value: id}
+}
+
+// Get finds a record by its primary key, gorm.ErrRecordNotFound is returned if there is none
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	var m T
+	if err := r.db.WithContext(ctx).Where(byID(id)).Take(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Update saves all fields of the record
+func (r *Repository[T]) Update(ctx context.Context, m *T) error {
+	return r.db.WithContext(ctx).Save(m).Error
+}
+
+// UpdateColumns updates the given columns of the record with the primary key,
+// gorm.ErrRecordNotFound is returned if there is none
+func (r *Repository[T]) UpdateColumns(ctx context.Context, id interface{}, columns map[string]interface{}) error {
+	res := r.DB(ctx).Where(byID(id)).Updates(columns)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	// MySQL counts the changed rows, not the matched ones, so a record left as is affects no row
+	exists, err := r.exists(r.DB(ctx).Where(byID(id)))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete deletes the record with the primary key, gorm.ErrRecordNotFound is returned if there is none
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	var m T
+	res := r.db.WithContext(ctx).Where(byID(id)).Delete(&m)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// List finds the records matching the query
+func (r *Repository[T]) List(ctx context.Context, q Query) ([]T, error) {
+	db, err := q.apply(r.DB(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var ms []T
+	if err = db.Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// Count counts the records matching the filters
+func (r *Repository[T]) Count(ctx context.Context, filters ...Filter) (int64, error) {
+	db, err := applyFilters(r.DB(ctx), filters)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if err = db.Count(&n).Error; err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Exists reports whether any record matches the filters
+func (r *Repository[T]) Exists(ctx context.Context, filters ...Filter) (bool, error) {
+	db, err := applyFilters(r.DB(ctx), filters)
+	if err != nil {
+		return false, err
+	}
+	return r.exists(db)
+}
+
+func (r *Repository[T]) exists(db *gorm.DB) (bool, error) {
+	var exists bool
+	if err := r.db.WithContext(db.Statement.Context).Raw("SELECT EXISTS(?)", db.Select("1")).Scan(&exists).Error; err != nil {
+		return false, err
+	}
+	return exists, nil
+}
@@ -0,0 +1,192 @@
+package sql
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type user struct {
+	ID   int64
+	Name string
+	Age  int
+}
+
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "user:pass@tcp(127.0.0.1:3306)/test",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	return db
+}
+
+func TestParseQuery(t *testing.T) {
+	fields := map[string]string{"name": "name", "age": "age", "created": "created_at"}
+	tests := []struct {
+		name    string
+		query   string
+		want    Query
+		wantErr bool
+	}{
+		{
+			"success",
+			"name=bob&age[gte]=18&sort=-created,name&limit=20&offset=40&page=2",
+			Query{
+				Filters: []Filter{Eq("name", "bob"), Gte("age", "18")},
+				Sorts:   []Sort{Desc("created_at"), Asc("name")},
+				Limit:   20,
+				Offset:  40,
+			},
+			false,
+		},
+		{
+			"like and in",
+			"name[like]=a%25b&age[in]=1,2",
+			Query{Filters: []Filter{Like("name", `%a\%b%`), In("age", "1", "2")}, Limit: DefaultListLimit},
+			false,
+		},
+		{
+			"limit capped",
+			"limit=5000",
+			Query{Limit: MaxListLimit},
+			false,
+		},
+		{"no limit", "", Query{Limit: DefaultListLimit}, false},
+		{"limit 0", "limit=0", Query{Limit: DefaultListLimit}, false},
+		{"not whitelisted field is ignored", "password=x", Query{Limit: DefaultListLimit}, false},
+		{"not whitelisted sort", "sort=password", Query{}, true},
+		{"unknown operator", "age[between]=1", Query{}, true},
+		{"invalid limit", "limit=-1", Query{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			got, err := ParseQuery(values, fields)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.want.Filters, got.Filters)
+			assert.Equal(t, tt.want.Sorts, got.Sorts)
+			assert.Equal(t, tt.want.Limit, got.Limit)
+			assert.Equal(t, tt.want.Offset, got.Offset)
+		})
+	}
+}
+
+func TestRepository_List(t *testing.T) {
+	db := dryRunDB(t)
+	repo := NewRepository[user](db)
+	q := Query{
+		Filters: []Filter{Eq("name", "bob"), In("age", 1, 2)},
+		Sorts:   []Sort{Desc("id")},
+		Limit:   10,
+	}
+	stmt := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		tx, err := q.apply(tx.Model(&user{}))
+		require.NoError(t, err)
+		return tx.Find(&[]user{})
+	})
+	assert.Equal(t, "SELECT * FROM `users` WHERE `name` = 'bob' AND `age` IN (1,2) ORDER BY `id` DESC LIMIT 10", stmt)
+
+	for limit, want := range map[int]string{0: "LIMIT 20", 5000: "LIMIT 1000"} {
+		stmt = db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			tx, err := Query{Limit: limit}.apply(tx.Model(&user{}))
+			require.NoError(t, err)
+			return tx.Find(&[]user{})
+		})
+		assert.Equal(t, "SELECT * FROM `users` "+want, stmt)
+	}
+
+	_, err := repo.List(context.Background(), Query{Filters: []Filter{{Field: "name", Op: "between"}}})
+	assert.Error(t, err)
+}
+
+func TestRepository_Upsert(t *testing.T) {
+	db := dryRunDB(t)
+	stmt := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Clauses(onDuplicateKeyUpdate([]string{"age"})).Create(&[]user{{ID: 1, Name: "bob", Age: 3}})
+	})
+	assert.Equal(t, "INSERT INTO `users` (`name`,`age`,`id`) VALUES ('bob',3,1) ON DUPLICATE KEY UPDATE `age`=VALUES(`age`)", stmt)
+}
+
+type item struct {
+	ID   string `gorm:"primaryKey;size:26"`
+	Name string
+}
+
+func TestRepository_ByID(t *testing.T) {
+	db := dryRunDB(t)
+	rec := &recorder{}
+	require.NoError(t, db.Use(NewTracer(TracerConfig{Recorder: rec})))
+	// the writes are not wrapped in a transaction, which needs a connection
+	repo := NewRepository[item](db.Session(&gorm.Session{SkipDefaultTransaction: true}))
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{
+			"get",
+			func() error { _, err := repo.Get(ctx, "01HABC"); return err },
+			"SELECT * FROM `items` WHERE `items`.`id` = ? LIMIT 1",
+		},
+		{
+			"get injection",
+			func() error { _, err := repo.Get(ctx, "1 OR 1=1"); return err },
+			"SELECT * FROM `items` WHERE `items`.`id` = ? LIMIT 1",
+		},
+		{
+			"delete",
+			func() error { return repo.Delete(ctx, "1 OR 1=1") },
+			"DELETE FROM `items` WHERE `items`.`id` = ?",
+		},
+		{
+			"exists",
+			func() error { _, err := repo.Exists(ctx, Eq("name", "bob")); return err },
+			"SELECT EXISTS(SELECT 1 FROM `items` WHERE `name` = ?)",
+		},
+		{
+			"count",
+			func() error { _, err := repo.Count(ctx, Eq("id", "01HABC")); return err },
+			"SELECT count(*) FROM `items` WHERE `id` = ?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec.infos = nil
+			_ = tt.call()
+			require.NotEmpty(t, rec.infos)
+			// the last statement is the outer one
+			assert.Equal(t, tt.want, rec.infos[len(rec.infos)-1].SQL)
+		})
+	}
+}
+
+func TestRepository_NotFound(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:repository_not_found?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&item{}))
+	repo := NewRepository[item](db)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, &item{ID: "01HABC", Name: "bob"}))
+
+	// a record left as is is found
+	assert.NoError(t, repo.UpdateColumns(ctx, "01HABC", map[string]interface{}{"name": "bob"}))
+	assert.ErrorIs(t, repo.UpdateColumns(ctx, "01HXYZ", map[string]interface{}{"name": "bob"}), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, "01HXYZ"), gorm.ErrRecordNotFound)
+	assert.NoError(t, repo.Delete(ctx, "01HABC"))
+	_, err = repo.Get(ctx, "01HABC")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
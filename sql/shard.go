@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/atong007/kit/uidgen"
+	"gorm.io/gorm"
+)
+
+// ShardFunc maps a shard key to a shard in [0, shards)
+type ShardFunc func(key int64, shards int) int
+
+// ShardByMod routes by the key modulo the number of shards
+func ShardByMod(key int64, shards int) int {
+	s := key % int64(shards)
+	if s < 0 {
+		s = -s
+	}
+	return int(s)
+}
+
+// ShardByNode routes the ids generated by generators with the layout of ug by
+// their node id modulo the number of shards. The records of a shard must be
+// created with a generator whose node id maps to that shard, then lookups by
+// id need no fan-out.
+func ShardByNode(ug *uidgen.UidGenerator) ShardFunc {
+	return func(id int64, shards int) int {
+		return ShardByMod(ug.Decompose(id).Node, shards)
+	}
+}
+
+// ShardRouter routes a shard key to one of several databases and a table suffix.
+// The shards are numbered across the databases, shard i lives in the database
+// i / tablesPerDB in the table with suffix "_i".
+type ShardRouter struct {
+	dbs         []*gorm.DB
+	tablesPerDB int
+	shard       ShardFunc
+}
+
+// NewShardRouter creates a new ShardRouter over the dbs returned by NewMySQL.
+// With a single table per database only the database is sharded and the tables have no suffix.
+func NewShardRouter(dbs []*gorm.DB, tablesPerDB int, shard ShardFunc) (*ShardRouter, error) {
+	if len(dbs) == 0 {
+		return nil, errors.New("at least one db is required")
+	}
+	if tablesPerDB <= 0 {
+		return nil, fmt.Errorf("invalid tables per db: %d", tablesPerDB)
+	}
+	if shard == nil {
+		shard = ShardByMod
+	}
+	return &ShardRouter{dbs: dbs, tablesPerDB: tablesPerDB, shard: shard}, nil
+}
+
+// Shards returns the total number of shards
+func (r *ShardRouter) Shards() int {
+	return len(r.dbs) * r.tablesPerDB
+}
+
+// Route returns the database and table suffix of the key, it fails if the
+// ShardFunc maps the key out of [0, Shards())
+func (r *ShardRouter) Route(key int64) (*gorm.DB, string, error) {
+	shard := r.shard(key, r.Shards())
+	if shard < 0 || shard >= r.Shards() {
+		return nil, "", fmt.Errorf("shard %d of key %d is out of [0, %d)", shard, key, r.Shards())
+	}
+	db, suffix := r.shardAt(shard)
+	return db, suffix, nil
+}
+
+// Table returns a session on the sharded table of the key, a routing error is
+// returned by the statements run on it
+func (r *ShardRouter) Table(ctx context.Context, table string, key int64) *gorm.DB {
+	db, suffix, err := r.Route(key)
+	if err != nil {
+		tx := r.dbs[0].WithContext(ctx)
+		_ = tx.AddError(err)
+		return tx
+	}
+	return db.WithContext(ctx).Table(table + suffix)
+}
+
+// Each calls fn for every shard, for the queries that can't be routed by key
+func (r *ShardRouter) Each(ctx context.Context, table string, fn func(tx *gorm.DB) error) error {
+	for i := 0; i < r.Shards(); i++ {
+		db, suffix := r.shardAt(i)
+		if err := fn(db.WithContext(ctx).Table(table + suffix)); err != nil {
+			return fmt.Errorf("error on shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *ShardRouter) shardAt(shard int) (*gorm.DB, string) {
+	db := r.dbs[shard/r.tablesPerDB]
+	if r.tablesPerDB == 1 {
+		return db, ""
+	}
+	return db, fmt.Sprintf("_%d", shard)
+}
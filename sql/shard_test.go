@@ -0,0 +1,85 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atong007/kit/uidgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestShardRouter_Route(t *testing.T) {
+	db0, db1 := dryRunDB(t), dryRunDB(t)
+	r, err := NewShardRouter([]*gorm.DB{db0, db1}, 4, ShardByMod)
+	require.NoError(t, err)
+	assert.Equal(t, 8, r.Shards())
+
+	tests := []struct {
+		name       string
+		key        int64
+		wantDB     *gorm.DB
+		wantSuffix string
+	}{
+		{"first shard", 8, db0, "_0"},
+		{"last table of first db", 3, db0, "_3"},
+		{"second db", 13, db1, "_5"},
+		{"negative key", -7, db1, "_7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, suffix, err := r.Route(tt.key)
+			require.NoError(t, err)
+			assert.Same(t, tt.wantDB, db)
+			assert.Equal(t, tt.wantSuffix, suffix)
+		})
+	}
+
+	tx := r.Table(context.Background(), "order", 13).Find(&[]user{})
+	assert.Equal(t, "SELECT * FROM `order_5`", tx.Statement.SQL.String())
+}
+
+func TestShardRouter_ByNode(t *testing.T) {
+	db0, db1 := dryRunDB(t), dryRunDB(t)
+	ug, err := uidgen.NewUidGenerator(3)
+	require.NoError(t, err)
+
+	r, err := NewShardRouter([]*gorm.DB{db0, db1}, 1, ShardByNode(ug))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		id, err := ug.Generate()
+		require.NoError(t, err)
+		db, suffix, err := r.Route(id)
+		require.NoError(t, err)
+		assert.Same(t, db1, db)
+		assert.Equal(t, "", suffix)
+	}
+}
+
+func TestShardRouter_OutOfRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		shard int
+	}{
+		{"negative", -1},
+		{"past the last shard", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewShardRouter([]*gorm.DB{dryRunDB(t), dryRunDB(t)}, 2, func(int64, int) int { return tt.shard })
+			require.NoError(t, err)
+
+			_, _, err = r.Route(1)
+			assert.Error(t, err)
+			assert.Error(t, r.Table(context.Background(), "order", 1).Find(&[]user{}).Error)
+		})
+	}
+}
+
+func TestNewShardRouter(t *testing.T) {
+	_, err := NewShardRouter(nil, 1, nil)
+	assert.Error(t, err)
+	_, err = NewShardRouter([]*gorm.DB{dryRunDB(t)}, 0, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package sqltest
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Factory builds model instances from a default template
+type Factory[T any] struct {
+	mu    sync.Mutex
+	seq   int
+	build func(seq int) T
+}
+
+// NewFactory creates a new Factory, build returns the default instance for a sequence number starting at 1
+func NewFactory[T any](build func(seq int) T) *Factory[T] {
+	return &Factory[T]{build: build}
+}
+
+// Build builds an instance, the overrides are applied to the default instance in order
+func (f *Factory[T]) Build(overrides ...func(*T)) T {
+	f.mu.Lock()
+	f.seq++
+	seq := f.seq
+	f.mu.Unlock()
+
+	m := f.build(seq)
+	for _, o := range overrides {
+		o(&m)
+	}
+	return m
+}
+
+// BuildList builds n instances
+func (f *Factory[T]) BuildList(n int, overrides ...func(*T)) []T {
+	ms := make([]T, n)
+	for i := range ms {
+		ms[i] = f.Build(overrides...)
+	}
+	return ms
+}
+
+// Create builds an instance and inserts it into the db
+func (f *Factory[T]) Create(t testing.TB, db *gorm.DB, overrides ...func(*T)) *T {
+	t.Helper()
+
+	m := f.Build(overrides...)
+	if err := db.Create(&m).Error; err != nil {
+		t.Fatalf("error creating %T: %v", m, err)
+	}
+	return &m
+}
@@ -0,0 +1,58 @@
+package sqltest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoadFixtures inserts the rows of the YAML fixture files.
+// The name of a file without extension is the table, its content is the list of rows:
+//
+//	# testdata/user.yml
+//	- id: 1
+//	  name: bob
+//	- id: 2
+//	  name: alice
+//
+// The tables are emptied before loading and when the test finishes, so that
+// the fixtures don't leak into the other tests and runs of a shared MySQL.
+// A file without rows only empties its table.
+func LoadFixtures(t testing.TB, db *gorm.DB, files ...string) {
+	t.Helper()
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error reading fixture %s: %v", file, err)
+		}
+		var rows []map[string]interface{}
+		if err = yaml.Unmarshal(b, &rows); err != nil {
+			t.Fatalf("error parsing fixture %s: %v", file, err)
+		}
+		table := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		// DELETE rather than TRUNCATE, which commits the transaction of Tx on MySQL
+		empty := func() error {
+			return db.Exec("DELETE FROM ?", clause.Table{Name: table}).Error
+		}
+		if err = empty(); err != nil {
+			t.Fatalf("error emptying fixture table %s: %v", table, err)
+		}
+		t.Cleanup(func() {
+			if err := empty(); err != nil {
+				t.Errorf("error emptying fixture table %s: %v", table, err)
+			}
+		})
+		if len(rows) == 0 {
+			continue
+		}
+		if err = db.Table(table).Create(&rows).Error; err != nil {
+			t.Fatalf("error loading fixture %s: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package sqltest provides the utilities for testing the code using gorm:
+// opening a test database, loading fixtures, isolating the tests in
+// transactions and building models with factories.
+package sqltest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MySQLDSNEnv is the env holding the DSN of the MySQL used by Open instead of SQLite
+const MySQLDSNEnv = "KIT_TEST_MYSQL_DSN"
+
+// Open opens the test database and migrates the models.
+// MySQL is used if the env KIT_TEST_MYSQL_DSN is set, a private in-memory SQLite otherwise.
+// The database is closed when the test finishes. The MySQL database is shared by
+// the tests and the runs, their rows must be written through Tx or LoadFixtures.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	var dialector gorm.Dialector
+	if dsn := os.Getenv(MySQLDSNEnv); dsn != "" {
+		dialector = mysql.Open(dsn)
+	} else {
+		name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+		dialector = sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("error opening test db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("error getting test db: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if len(models) > 0 {
+		if err = db.AutoMigrate(models...); err != nil {
+			t.Fatalf("error migrating test db: %v", err)
+		}
+	}
+	return db
+}
+
+// Tx begins a transaction which is rolled back when the test finishes,
+// so that the changes of the test are invisible to the others
+func Tx(t testing.TB, db *gorm.DB) *gorm.DB {
+	t.Helper()
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("error beginning test transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
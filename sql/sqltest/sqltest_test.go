@@ -0,0 +1,84 @@
+package sqltest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/atong007/kit/sql"
+	"github.com/atong007/kit/sql/sqltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Account struct {
+	ID   int64
+	Name string
+	Age  int
+}
+
+var accounts = sqltest.NewFactory(func(seq int) Account {
+	return Account{Name: fmt.Sprintf("user%d", seq), Age: 18}
+})
+
+func TestFixturesAndTx(t *testing.T) {
+	db := sqltest.Open(t, &Account{})
+	sqltest.LoadFixtures(t, db, "testdata/account.yml")
+
+	t.Run("create in tx", func(t *testing.T) {
+		tx := sqltest.Tx(t, db)
+		accounts.Create(t, tx, func(a *Account) { a.Age = 40 })
+
+		repo := sql.NewRepository[Account](tx)
+		n, err := repo.Count(context.Background(), sql.Gte("age", 30))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+
+	repo := sql.NewRepository[Account](db)
+	n, err := repo.Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n, "changes of the transaction must be rolled back")
+
+	got, err := repo.Get(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.Name)
+
+	exists, err := repo.Exists(context.Background(), sql.Eq("name", "bob"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestLoadFixtures_Cleanup(t *testing.T) {
+	db := sqltest.Open(t, &Account{})
+	repo := sql.NewRepository[Account](db)
+	for i := 0; i < 2; i++ {
+		t.Run(fmt.Sprintf("run %d", i), func(t *testing.T) {
+			sqltest.LoadFixtures(t, db, "testdata/account.yml")
+			n, err := repo.Count(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+		})
+	}
+	n, err := repo.Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n, "fixtures must be removed when the test finishes")
+}
+
+func TestLoadFixtures_Empty(t *testing.T) {
+	db := sqltest.Open(t, &Account{})
+	accounts.Create(t, db)
+
+	sqltest.LoadFixtures(t, db, "testdata/empty/account.yml")
+	n, err := sql.NewRepository[Account](db).Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestFactory_Build(t *testing.T) {
+	f := sqltest.NewFactory(func(seq int) Account {
+		return Account{Name: fmt.Sprintf("user%d", seq)}
+	})
+	list := f.BuildList(2, func(a *Account) { a.Age = 1 })
+	assert.Equal(t, []Account{{Name: "user1", Age: 1}, {Name: "user2", Age: 1}}, list)
+}
@@ -0,0 +1,190 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atong007/kit/log"
+	"gorm.io/gorm"
+)
+
+const (
+	tracerName     = "kit:tracer"
+	tracerStartKey = "kit:tracer:start"
+)
+
+// QueryInfo describes an executed statement
+type QueryInfo struct {
+	// SQL is the statement with its placeholders, the bound values are left out
+	// as they may hold passwords, tokens or personal data
+	SQL          string
+	Table        string
+	Operation    string
+	Duration     time.Duration
+	RowsAffected int64
+	Caller       string
+	RequestID    string
+	Err          error
+}
+
+// QueryRecorder records the executed statements, e.g. into metrics
+type QueryRecorder interface {
+	RecordQuery(ctx context.Context, info QueryInfo)
+}
+
+// TracerConfig is the config of Tracer
+type TracerConfig struct {
+	// SlowThreshold is the duration above which a statement is logged, 0 disables the slow query log
+	SlowThreshold time.Duration
+	// Logger receives the slow statements
+	Logger log.Logger
+	// RequestID extracts the request id from the context of the statement
+	RequestID func(ctx context.Context) string
+	// Recorder receives every executed statement
+	Recorder QueryRecorder
+	// ExplainSlowSelect runs EXPLAIN for the slow SELECT statements and logs the plan
+	ExplainSlowSelect bool
+}
+
+// Tracer is a gorm plugin recording the duration, rows affected, caller and
+// request id of every statement and logging the slow ones, register it with db.Use
+type Tracer struct {
+	conf TracerConfig
+}
+
+// NewTracer creates a new Tracer
+func NewTracer(conf TracerConfig) *Tracer {
+	return &Tracer{conf: conf}
+}
+
+func (t *Tracer) Name() string {
+	return tracerName
+}
+
+func (t *Tracer) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	type registerer interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}
+	register := func(op string, before, after registerer) error {
+		if err := before.Register(tracerName+":before_"+op, t.before); err != nil {
+			return err
+		}
+		return after.Register(tracerName+":after_"+op, func(db *gorm.DB) { t.after(db, op) })
+	}
+
+	if err := register("create", cb.Create().Before("*"), cb.Create().After("*")); err != nil {
+		return err
+	}
+	if err := register("query", cb.Query().Before("*"), cb.Query().After("*")); err != nil {
+		return err
+	}
+	if err := register("update", cb.Update().Before("*"), cb.Update().After("*")); err != nil {
+		return err
+	}
+	if err := register("delete", cb.Delete().Before("*"), cb.Delete().After("*")); err != nil {
+		return err
+	}
+	if err := register("row", cb.Row().Before("*"), cb.Row().After("*")); err != nil {
+		return err
+	}
+	return register("raw", cb.Raw().Before("*"), cb.Raw().After("*"))
+}
+
+type explainKey struct{}
+
+func (t *Tracer) before(db *gorm.DB) {
+	db.InstanceSet(tracerStartKey, time.Now())
+}
+
+func (t *Tracer) after(db *gorm.DB, op string) {
+	v, ok := db.InstanceGet(tracerStartKey)
+	if !ok {
+		return
+	}
+	start, ok := v.(time.Time)
+	if !ok {
+		return
+	}
+	ctx := db.Statement.Context
+	if ctx != nil && ctx.Value(explainKey{}) != nil {
+		return
+	}
+	elapsed := time.Since(start)
+	slow := t.conf.SlowThreshold > 0 && elapsed > t.conf.SlowThreshold && t.conf.Logger != nil
+	if t.conf.Recorder == nil && !slow {
+		return
+	}
+
+	info := QueryInfo{
+		SQL:          db.Statement.SQL.String(),
+		Table:        db.Statement.Table,
+		Operation:    op,
+		Duration:     elapsed,
+		RowsAffected: db.Statement.RowsAffected,
+		Caller:       caller(),
+	}
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		info.Err = db.Error
+	}
+	if t.conf.RequestID != nil && ctx != nil {
+		info.RequestID = t.conf.RequestID(ctx)
+	}
+
+	if t.conf.Recorder != nil {
+		t.conf.Recorder.RecordQuery(ctx, info)
+	}
+	if slow {
+		t.conf.Logger.Infof("slow query [%s] request_id=%s rows=%d caller=%s: %s",
+			info.Duration, info.RequestID, info.RowsAffected, info.Caller, info.SQL)
+		if t.conf.ExplainSlowSelect && isSelect(info.SQL) {
+			t.explain(db)
+		}
+	}
+}
+
+func (t *Tracer) explain(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var plan []map[string]interface{}
+	err := db.Session(&gorm.Session{NewDB: true, Context: context.WithValue(ctx, explainKey{}, true)}).
+		Raw("EXPLAIN "+db.Statement.SQL.String(), db.Statement.Vars...).
+		Scan(&plan).Error
+	if err != nil {
+		t.conf.Logger.Errorf("error explaining slow query: %v", err)
+		return
+	}
+	t.conf.Logger.Infof("slow query plan: %v", plan)
+}
+
+func isSelect(sql string) bool {
+	sql = strings.TrimSpace(sql)
+	return len(sql) >= 6 && strings.EqualFold(sql[:6], "SELECT")
+}
+
+var sqlSourceDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// caller returns the first caller outside gorm and this package
+func caller() string {
+	for i := 2; i < 20; i++ {
+		_, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		if strings.HasSuffix(file, "_test.go") ||
+			(!strings.Contains(file, "gorm.io/") && filepath.Dir(file) != sqlSourceDir) {
+			return file + ":" + strconv.Itoa(line)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type requestIDKey struct{}
+
+type recordingLogger struct {
+	infos []string
+}
+
+func (l *recordingLogger) Debug(args ...interface{})                 {}
+func (l *recordingLogger) Info(args ...interface{})                  {}
+func (l *recordingLogger) Error(args ...interface{})                 {}
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {}
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {}
+
+type recorder struct {
+	infos []QueryInfo
+}
+
+func (r *recorder) RecordQuery(_ context.Context, info QueryInfo) {
+	r.infos = append(r.infos, info)
+}
+
+func TestTracer(t *testing.T) {
+	db := dryRunDB(t)
+	logger := &recordingLogger{}
+	rec := &recorder{}
+	hist := NewQueryHistogram()
+	require.NoError(t, db.Use(NewTracer(TracerConfig{
+		SlowThreshold: time.Nanosecond,
+		Logger:        logger,
+		RequestID: func(ctx context.Context) string {
+			id, _ := ctx.Value(requestIDKey{}).(string)
+			return id
+		},
+		Recorder: rec,
+	})))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	var users []user
+	require.NoError(t, db.WithContext(ctx).Where("age > ?", 18).Find(&users).Error)
+
+	require.Len(t, rec.infos, 1)
+	info := rec.infos[0]
+	hist.RecordQuery(ctx, info)
+	assert.Equal(t, "SELECT * FROM `users` WHERE age > ?", info.SQL)
+	assert.Equal(t, "users", info.Table)
+	assert.Equal(t, "query", info.Operation)
+	assert.Equal(t, "req-1", info.RequestID)
+	assert.True(t, strings.Contains(info.Caller, "tracer_test.go"), info.Caller)
+
+	require.Len(t, logger.infos, 1)
+	assert.True(t, strings.HasPrefix(logger.infos[0], "slow query"))
+	assert.False(t, strings.Contains(logger.infos[0], "18"), logger.infos[0])
+
+	snap := hist.Snapshot()["users:query"]
+	assert.Equal(t, uint64(1), snap.Count)
+	assert.Equal(t, uint64(1), snap.Counts[0])
+}
@@ -0,0 +1,99 @@
+package uidgen
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/atong007/kit/internal/owner"
+)
+
+// Different types of error returned by the node allocators
+var (
+	ErrNoFreeNode = errors.New("no free node id")
+	ErrLeaseLost  = errors.New("node id lease has been lost")
+)
+
+// NodeAllocator leases node ids from a coordination store
+type NodeAllocator interface {
+	// Acquire leases a free node id in [0, maxNode] for ttl
+	Acquire(ctx context.Context, maxNode int64, ttl time.Duration) (NodeLease, error)
+}
+
+// NodeLease is a leased node id
+type NodeLease interface {
+	// NodeId returns the leased node id
+	NodeId() int64
+
+	// Renew extends the lease by its ttl, ErrLeaseLost is returned if the lease has expired
+	Renew(ctx context.Context) error
+
+	// Release releases the node id
+	Release(ctx context.Context) error
+}
+
+// MemoryNodeAllocator is a NodeAllocator keeping the leases in memory, for tests
+type MemoryNodeAllocator struct {
+	mu     sync.Mutex
+	leases map[int64]memoryLease
+}
+
+type memoryLease struct {
+	owner     string
+	expiredAt time.Time
+}
+
+// NewMemoryNodeAllocator creates a new MemoryNodeAllocator
+func NewMemoryNodeAllocator() *MemoryNodeAllocator {
+	return &MemoryNodeAllocator{leases: map[int64]memoryLease{}}
+}
+
+func (m *MemoryNodeAllocator) Acquire(_ context.Context, maxNode int64, ttl time.Duration) (NodeLease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id := int64(0); id <= maxNode; id++ {
+		if l, ok := m.leases[id]; ok && now.Before(l.expiredAt) {
+			continue
+		}
+		holder := owner.New()
+		m.leases[id] = memoryLease{owner: holder, expiredAt: now.Add(ttl)}
+		return &memoryNodeLease{alloc: m, nodeId: id, owner: holder, ttl: ttl}, nil
+	}
+	return nil, ErrNoFreeNode
+}
+
+type memoryNodeLease struct {
+	alloc  *MemoryNodeAllocator
+	nodeId int64
+	owner  string
+	ttl    time.Duration
+}
+
+func (l *memoryNodeLease) NodeId() int64 {
+	return l.nodeId
+}
+
+func (l *memoryNodeLease) Renew(_ context.Context) error {
+	l.alloc.mu.Lock()
+	defer l.alloc.mu.Unlock()
+	cur, ok := l.alloc.leases[l.nodeId]
+	now := time.Now()
+	if !ok || cur.owner != l.owner || !now.Before(cur.expiredAt) {
+		return ErrLeaseLost
+	}
+	l.alloc.leases[l.nodeId] = memoryLease{owner: l.owner, expiredAt: now.Add(l.ttl)}
+	return nil
+}
+
+func (l *memoryNodeLease) Release(_ context.Context) error {
+	l.alloc.mu.Lock()
+	defer l.alloc.mu.Unlock()
+	if cur, ok := l.alloc.leases[l.nodeId]; ok && cur.owner == l.owner {
+		delete(l.alloc.leases, l.nodeId)
+	}
+	return nil
+}
+
+var _ NodeAllocator = (*MemoryNodeAllocator)(nil)
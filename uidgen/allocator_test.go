@@ -0,0 +1,84 @@
+package uidgen_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atong007/kit/uidgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lostLease struct {
+	uidgen.NodeLease
+}
+
+func (l lostLease) Renew(ctx context.Context) error {
+	return uidgen.ErrLeaseLost
+}
+
+type lostAllocator struct {
+	*uidgen.MemoryNodeAllocator
+}
+
+func (a lostAllocator) Acquire(ctx context.Context, maxNode int64, ttl time.Duration) (uidgen.NodeLease, error) {
+	l, err := a.MemoryNodeAllocator.Acquire(ctx, maxNode, ttl)
+	return lostLease{l}, err
+}
+
+func TestNewLeasedUidGenerator(t *testing.T) {
+	layout := uidgen.DefaultLayout
+	layout.NodeBits = 1
+	alloc := uidgen.NewMemoryNodeAllocator()
+	ctx := context.Background()
+
+	ug1, err := uidgen.NewLeasedUidGenerator(ctx, alloc, time.Minute, uidgen.WithLayout(layout))
+	require.NoError(t, err)
+	ug2, err := uidgen.NewLeasedUidGenerator(ctx, alloc, time.Minute, uidgen.WithLayout(layout))
+	require.NoError(t, err)
+	assert.NotEqual(t, ug1.NodeId(), ug2.NodeId())
+
+	_, err = uidgen.NewLeasedUidGenerator(ctx, alloc, time.Minute, uidgen.WithLayout(layout))
+	assert.ErrorIs(t, err, uidgen.ErrNoFreeNode)
+
+	id, err := ug1.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, ug1.NodeId(), ug1.Decompose(id).Node)
+
+	require.NoError(t, ug1.Close(ctx))
+	_, err = ug1.Generate()
+	assert.ErrorIs(t, err, uidgen.ErrClosed)
+
+	ug3, err := uidgen.NewLeasedUidGenerator(ctx, alloc, time.Minute, uidgen.WithLayout(layout))
+	require.NoError(t, err)
+	assert.Equal(t, ug1.NodeId(), ug3.NodeId())
+}
+
+func TestNewLeasedUidGenerator_LeaseLost(t *testing.T) {
+	alloc := lostAllocator{uidgen.NewMemoryNodeAllocator()}
+	ug, err := uidgen.NewLeasedUidGenerator(context.Background(), alloc, time.Millisecond*30)
+	require.NoError(t, err)
+	_, err = ug.Generate()
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := ug.Generate()
+		return err == uidgen.ErrLeaseLost
+	}, time.Second, time.Millisecond*5)
+	assert.Panics(t, func() { ug.NewId() })
+}
+
+func TestMemoryNodeAllocator_Renew(t *testing.T) {
+	alloc := uidgen.NewMemoryNodeAllocator()
+	ctx := context.Background()
+	lease, err := alloc.Acquire(ctx, 0, time.Millisecond*10)
+	require.NoError(t, err)
+	require.NoError(t, lease.Renew(ctx))
+
+	time.Sleep(time.Millisecond * 20)
+	other, err := alloc.Acquire(ctx, 0, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, lease.NodeId(), other.NodeId())
+	assert.ErrorIs(t, lease.Renew(ctx), uidgen.ErrLeaseLost)
+}
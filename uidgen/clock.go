@@ -0,0 +1,199 @@
+package uidgen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrClockBackwards is returned by Generate when the clock has moved backwards
+// further than the ClockPolicy allows
+var ErrClockBackwards = errors.New("clock moved backwards")
+
+// ClockPolicy decides what Generate does when the clock moves backwards. The
+// default clock is monotonic within a process, so the rollbacks come from a
+// clock set by WithClock, or from a restart behind the state of WithStateFile.
+type ClockPolicy struct {
+	// MaxWait is the longest rollback Generate waits for the clock to catch
+	// up with, 0 never waits
+	MaxWait time.Duration
+
+	// ReservedBits are the high sequence bits counting the rollbacks. A longer
+	// rollback switches to the next count and keeps generating ids which are
+	// unique but no longer ordered, until the count is exhausted. Without
+	// reserved bits ErrClockBackwards is returned.
+	ReservedBits uint8
+}
+
+// DefaultClockPolicy waits up to a second and fails for longer rollbacks
+var DefaultClockPolicy = ClockPolicy{MaxWait: time.Second}
+
+// WithClockPolicy sets the rollback policy of the generator, DefaultClockPolicy is used otherwise
+func WithClockPolicy(p ClockPolicy) Option {
+	return func(ug *UidGenerator) {
+		ug.clockPolicy = p
+	}
+}
+
+// WithClock sets the clock of the generator, time.Now is used otherwise
+func WithClock(now func() time.Time) Option {
+	return func(ug *UidGenerator) {
+		ug.now = now
+	}
+}
+
+// WithStateFile persists the time of the issued ids and the rollback count of
+// the ClockPolicy to the file, so that a restarted generator never reuses them
+// even if the clock has moved backwards.
+// The file is written once per window, a restart waits for the rest of the
+// last window.
+func WithStateFile(path string, window time.Duration) Option {
+	return func(ug *UidGenerator) {
+		ug.stateFile = path
+		ug.stateWindow = window
+	}
+}
+
+// rollback handles the clock having moved backwards from the last issued time
+// to now and returns the time to continue with
+func (ug *UidGenerator) rollback(now int64) (int64, error) {
+	unit := ug.layout.TimeUnit
+	back := time.Duration(ug.lastTime-now) * unit
+	if back <= ug.clockPolicy.MaxWait {
+		deadline := time.Now().Add(ug.clockPolicy.MaxWait)
+		for now < ug.lastTime && time.Now().Before(deadline) {
+			time.Sleep(time.Duration(ug.lastTime-now) * unit)
+			now = ug.since()
+		}
+		if now >= ug.lastTime {
+			return now, nil
+		}
+	}
+
+	reserved := ug.clockPolicy.ReservedBits
+	if reserved == 0 {
+		return 0, fmt.Errorf("%w by %s", ErrClockBackwards, back)
+	}
+	if ug.rollbacks == 1<<reserved-1 {
+		return 0, fmt.Errorf("%w by %s: reserved sequence space exhausted", ErrClockBackwards, back)
+	}
+	ug.rollbacks++
+	// restart the sequence for the new rollback count
+	ug.lastTime = -1
+	return now, nil
+}
+
+// restoreState reads the last persisted time and rollback count, and waits
+// for the time to pass if it lies within a window from now
+func (ug *UidGenerator) restoreState() error {
+	if ug.stateWindow <= 0 {
+		ug.stateWindow = time.Second
+	}
+	b, err := os.ReadFile(ug.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading state file: %w", err)
+	}
+	// "<unix nanos> <rollbacks>", the rollbacks are missing from older files
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 || len(fields) > 2 {
+		return fmt.Errorf("error parsing state file: %q", b)
+	}
+	nanos, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("error parsing state file: %w", err)
+	}
+	var rollbacks int64
+	if len(fields) == 2 {
+		if rollbacks, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return fmt.Errorf("error parsing state file: %w", err)
+		}
+	}
+	if max := int64(1)<<ug.clockPolicy.ReservedBits - 1; rollbacks > max {
+		return fmt.Errorf("%w: %d rollbacks in the state file exceed the reserved bits", ErrClockBackwards, rollbacks)
+	}
+	ug.rollbacks, ug.persistedRollbacks = rollbacks, rollbacks
+
+	mark, ok := ug.units(time.Unix(0, nanos))
+	if !ok {
+		return nil
+	}
+	ug.lastTime, ug.persisted = mark, mark
+
+	if wait := time.Duration(mark-ug.since()+1) * ug.layout.TimeUnit; wait > 0 && wait <= ug.stateWindow {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// mustPersist reports whether the state file has to be written before issuing
+// an id at now: now has passed the persisted mark or the rollback count changed
+func (ug *UidGenerator) mustPersist(now int64) bool {
+	return ug.stateFile != "" && (now >= ug.persisted || ug.rollbacks != ug.persistedRollbacks)
+}
+
+// persist writes a mark a window ahead of now and the rollback count. It is
+// called with ug.mu held and releases it during the write, so that the fsync
+// doesn't hold up Close and the ids still covered by the previous mark. A
+// concurrent call waits for the write in progress instead.
+func (ug *UidGenerator) persist(now int64) error {
+	if done := ug.persisting; done != nil {
+		ug.mu.Unlock()
+		<-done
+		ug.mu.Lock()
+		return nil
+	}
+
+	mark := now + int64(ug.stateWindow/ug.layout.TimeUnit) + 1
+	if mark < ug.persisted {
+		// after a rollback the mark must not move backwards
+		mark = ug.persisted
+	}
+	t := ug.layout.Epoch.Add(time.Duration(mark) * ug.layout.TimeUnit)
+	rollbacks := ug.rollbacks
+	done := make(chan struct{})
+	ug.persisting = done
+	ug.mu.Unlock()
+
+	err := writeState(ug.stateFile, fmt.Sprintf("%d %d", t.UnixNano(), rollbacks))
+
+	ug.mu.Lock()
+	ug.persisting = nil
+	close(done)
+	if err != nil {
+		return err
+	}
+	if mark > ug.persisted {
+		ug.persisted = mark
+	}
+	ug.persistedRollbacks = rollbacks
+	return nil
+}
+
+// writeState atomically replaces the state file with state
+func writeState(path, state string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(state); err == nil {
+		err = tmp.Sync()
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	return nil
+}
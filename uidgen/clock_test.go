@@ -0,0 +1,144 @@
+package uidgen_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atong007/kit/uidgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestUidGenerator_ClockBackwards(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  uidgen.ClockPolicy
+		back    time.Duration
+		wantErr bool
+	}{
+		{"error", uidgen.ClockPolicy{}, time.Millisecond, true},
+		{"borrow reserved bits", uidgen.ClockPolicy{ReservedBits: 2}, time.Second, false},
+		{"too long to wait", uidgen.ClockPolicy{MaxWait: time.Millisecond}, time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Now()}
+			ug, err := uidgen.NewUidGenerator(1, uidgen.WithClock(clock.Now), uidgen.WithClockPolicy(tt.policy))
+			require.NoError(t, err)
+
+			before, err := ug.Generate()
+			require.NoError(t, err)
+			clock.Add(-tt.back)
+			after, err := ug.Generate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, uidgen.ErrClockBackwards)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEqual(t, before, after)
+		})
+	}
+}
+
+func TestUidGenerator_ReservedBitsExhausted(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	ug, err := uidgen.NewUidGenerator(1, uidgen.WithClock(clock.Now), uidgen.WithClockPolicy(uidgen.ClockPolicy{ReservedBits: 1}))
+	require.NoError(t, err)
+
+	ids := map[int64]bool{}
+	for i := 0; i < 2; i++ {
+		id, err := ug.Generate()
+		require.NoError(t, err)
+		ids[id] = true
+		clock.Add(-time.Second)
+	}
+	assert.Len(t, ids, 2)
+	_, err = ug.Generate()
+	assert.ErrorIs(t, err, uidgen.ErrClockBackwards)
+}
+
+func TestUidGenerator_StateFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "uidgen.state")
+	clock := &fakeClock{now: time.Now()}
+	ug, err := uidgen.NewUidGenerator(1, uidgen.WithClock(clock.Now), uidgen.WithStateFile(file, time.Minute))
+	require.NoError(t, err)
+	last, err := ug.Generate()
+	require.NoError(t, err)
+
+	// a restart with the clock an hour behind must not reuse the ids
+	clock.Add(-time.Hour)
+	restarted, err := uidgen.NewUidGenerator(1, uidgen.WithClock(clock.Now), uidgen.WithStateFile(file, time.Minute), uidgen.WithClockPolicy(uidgen.ClockPolicy{}))
+	require.NoError(t, err)
+	_, err = restarted.Generate()
+	assert.ErrorIs(t, err, uidgen.ErrClockBackwards)
+
+	clock.Add(time.Hour + time.Minute*2)
+	id, err := restarted.Generate()
+	require.NoError(t, err)
+	assert.Greater(t, id, last)
+}
+
+func TestUidGenerator_StateFileRollbacks(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "uidgen.state")
+	clock := &fakeClock{now: time.Now()}
+	policy := uidgen.WithClockPolicy(uidgen.ClockPolicy{ReservedBits: 1})
+	ug, err := uidgen.NewUidGenerator(1, uidgen.WithClock(clock.Now), uidgen.WithStateFile(file, time.Minute), policy)
+	require.NoError(t, err)
+	_, err = ug.Generate()
+	require.NoError(t, err)
+	clock.Add(-time.Hour)
+	_, err = ug.Generate()
+	require.NoError(t, err, "the rollback borrows the reserved bit")
+
+	// the restarted generator must not borrow the same count again
+	restarted, err := uidgen.NewUidGenerator(1, uidgen.WithClock(clock.Now), uidgen.WithStateFile(file, time.Minute), policy)
+	require.NoError(t, err)
+	_, err = restarted.Generate()
+	assert.ErrorIs(t, err, uidgen.ErrClockBackwards)
+}
+
+func TestUidGenerator_StateFileConcurrent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "uidgen.state")
+	ug, err := uidgen.NewUidGenerator(1, uidgen.WithStateFile(file, time.Millisecond))
+	require.NoError(t, err)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		ids = map[int64]bool{}
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				id, err := ug.Generate()
+				assert.NoError(t, err)
+				mu.Lock()
+				ids[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, ids, 1600)
+}
@@ -0,0 +1,267 @@
+package uidgen
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Different types of error returned by the codecs
+var (
+	ErrInvalidEncoding = errors.New("invalid encoded id")
+	ErrNegativeId      = errors.New("id must not be negative")
+)
+
+// Codec encodes ids as short strings in the positional system of an alphabet
+type Codec struct {
+	alphabet string
+	index    [256]byte
+	// width is the length of the longest encoded id
+	width int
+}
+
+// The base62 and bitcoin base58 codecs
+var (
+	Base62 = mustCodec("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+	Base58 = mustCodec("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
+)
+
+// NewCodec creates a new Codec over an alphabet of at least 2 distinct ASCII characters
+func NewCodec(alphabet string) (*Codec, error) {
+	if len(alphabet) < 2 {
+		return nil, errors.New("alphabet must have at least 2 characters")
+	}
+	c := &Codec{alphabet: alphabet}
+	for i := range c.index {
+		c.index[i] = 0xFF
+	}
+	for i := 0; i < len(alphabet); i++ {
+		if alphabet[i] >= 0x80 || c.index[alphabet[i]] != 0xFF {
+			return nil, fmt.Errorf("invalid or duplicated character %q in alphabet", alphabet[i])
+		}
+		c.index[alphabet[i]] = byte(i)
+	}
+	longest, _ := c.Encode(math.MaxInt64)
+	c.width = len(longest)
+	return c, nil
+}
+
+func mustCodec(alphabet string) *Codec {
+	c, err := NewCodec(alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Encode encodes a non-negative id
+func (c *Codec) Encode(id int64) (string, error) {
+	if id < 0 {
+		return "", ErrNegativeId
+	}
+	base := int64(len(c.alphabet))
+	if id == 0 {
+		return c.alphabet[:1], nil
+	}
+	var buf [64]byte
+	i := len(buf)
+	for id > 0 {
+		i--
+		buf[i] = c.alphabet[id%base]
+		id /= base
+	}
+	return string(buf[i:]), nil
+}
+
+// Decode decodes an id encoded by Encode. Only the canonical form is accepted,
+// leading zero digits would let several strings decode to the same id.
+func (c *Codec) Decode(s string) (int64, error) {
+	if s == "" || len(s) > c.width || len(s) > 1 && s[0] == c.alphabet[0] {
+		return 0, ErrInvalidEncoding
+	}
+	base := uint64(len(c.alphabet))
+	var id uint64
+	for i := 0; i < len(s); i++ {
+		d := c.index[s[i]]
+		if d == 0xFF {
+			return 0, ErrInvalidEncoding
+		}
+		if id > (1<<63-1-uint64(d))/base {
+			return 0, ErrInvalidEncoding
+		}
+		id = id*base + uint64(d)
+	}
+	return int64(id), nil
+}
+
+const (
+	feistelRounds = 8
+	idMask        = uint64(1)<<63 - 1
+)
+
+// Obfuscator is a keyed reversible permutation of the non-negative int64 ids,
+// hiding the order and volume of sequential ids. It is a 64 bits Feistel
+// cipher with HMAC-SHA256 round functions, cycle walking keeps the results
+// within 63 bits.
+type Obfuscator struct {
+	key []byte
+}
+
+// NewObfuscator creates a new Obfuscator with a secret key of at least 16 bytes
+func NewObfuscator(key []byte) (*Obfuscator, error) {
+	if len(key) < 16 {
+		return nil, errors.New("the key must be at least 16 bytes long")
+	}
+	return &Obfuscator{key: append([]byte(nil), key...)}, nil
+}
+
+// Obfuscate maps the id to its obfuscated form
+func (o *Obfuscator) Obfuscate(id int64) (int64, error) {
+	if id < 0 {
+		return 0, ErrNegativeId
+	}
+	v := uint64(id)
+	for {
+		v = o.encrypt(v)
+		if v <= idMask {
+			return int64(v), nil
+		}
+	}
+}
+
+// Reveal maps the obfuscated form back to the id
+func (o *Obfuscator) Reveal(id int64) (int64, error) {
+	if id < 0 {
+		return 0, ErrNegativeId
+	}
+	v := uint64(id)
+	for {
+		v = o.decrypt(v)
+		if v <= idMask {
+			return int64(v), nil
+		}
+	}
+}
+
+func (o *Obfuscator) encrypt(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := 0; i < feistelRounds; i++ {
+		l, r = r, l^o.round(i, r)
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (o *Obfuscator) decrypt(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := feistelRounds - 1; i >= 0; i-- {
+		l, r = r^o.round(i, l), l
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (o *Obfuscator) round(i int, half uint32) uint32 {
+	var msg [5]byte
+	msg[0] = byte(i)
+	binary.BigEndian.PutUint32(msg[1:], half)
+	mac := hmac.New(sha256.New, o.key)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
+
+// PublicIdCodec turns ids into obfuscated short strings for URLs and back
+type PublicIdCodec struct {
+	obfuscator *Obfuscator
+	codec      *Codec
+}
+
+// NewPublicIdCodec creates a new PublicIdCodec, Base62 is used if codec is nil
+func NewPublicIdCodec(key []byte, codec *Codec) (*PublicIdCodec, error) {
+	o, err := NewObfuscator(key)
+	if err != nil {
+		return nil, err
+	}
+	if codec == nil {
+		codec = Base62
+	}
+	return &PublicIdCodec{obfuscator: o, codec: codec}, nil
+}
+
+// Encode returns the public form of the id
+func (p *PublicIdCodec) Encode(id int64) (string, error) {
+	o, err := p.obfuscator.Obfuscate(id)
+	if err != nil {
+		return "", err
+	}
+	return p.codec.Encode(o)
+}
+
+// Decode returns the id of the public form
+func (p *PublicIdCodec) Decode(s string) (int64, error) {
+	o, err := p.codec.Decode(s)
+	if err != nil {
+		return 0, err
+	}
+	return p.obfuscator.Reveal(o)
+}
+
+// StringId is an int64 id marshalled as a JSON string, since JavaScript
+// numbers lose the precision of large ids. Both strings and numbers are
+// accepted when unmarshalling, it is stored as an int64 by gorm.
+type StringId int64
+
+func (s StringId) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatInt(int64(s), 10) + `"`), nil
+}
+
+func (s *StringId) UnmarshalJSON(b []byte) error {
+	str := string(b)
+	if str == "null" {
+		return nil
+	}
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	v, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %s: %w", b, err)
+	}
+	*s = StringId(v)
+	return nil
+}
+
+func (s StringId) String() string {
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Value implements driver.Valuer
+func (s StringId) Value() (driver.Value, error) {
+	return int64(s), nil
+}
+
+// Scan implements sql.Scanner
+func (s *StringId) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*s = StringId(v)
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		*s = StringId(n)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*s = StringId(n)
+	default:
+		return fmt.Errorf("unsupported type %T for id", src)
+	}
+	return nil
+}
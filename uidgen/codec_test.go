@@ -0,0 +1,104 @@
+package uidgen_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/atong007/kit/uidgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec *uidgen.Codec
+		id    int64
+		want  string
+	}{
+		{"base62 zero", uidgen.Base62, 0, "0"},
+		{"base62", uidgen.Base62, 3843, "zz"},
+		{"base62 max", uidgen.Base62, math.MaxInt64, "AzL8n0Y58m7"},
+		{"base58 zero", uidgen.Base58, 0, "1"},
+		{"base58", uidgen.Base58, 58, "21"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.codec.Encode(tt.id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			id, err := tt.codec.Decode(got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.id, id)
+		})
+	}
+
+	_, err := uidgen.Base62.Encode(-1)
+	assert.ErrorIs(t, err, uidgen.ErrNegativeId)
+	_, err = uidgen.Base62.Decode("AzL8n0Y58m8")
+	assert.ErrorIs(t, err, uidgen.ErrInvalidEncoding)
+	_, err = uidgen.Base58.Decode("0")
+	assert.ErrorIs(t, err, uidgen.ErrInvalidEncoding)
+}
+
+func TestCodec_DecodeNonCanonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec *uidgen.Codec
+		s     string
+	}{
+		{"empty", uidgen.Base62, ""},
+		{"leading zero", uidgen.Base62, "0zz"},
+		{"zeros", uidgen.Base62, "00"},
+		{"base58 leading zero", uidgen.Base58, "121"},
+		{"longer than the width", uidgen.Base62, "111111111111"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.codec.Decode(tt.s)
+			assert.ErrorIs(t, err, uidgen.ErrInvalidEncoding)
+		})
+	}
+}
+
+func TestPublicIdCodec(t *testing.T) {
+	p, err := uidgen.NewPublicIdCodec([]byte("0123456789abcdef"), nil)
+	require.NoError(t, err)
+	other, err := uidgen.NewPublicIdCodec([]byte("fedcba9876543210"), uidgen.Base58)
+	require.NoError(t, err)
+
+	ug, err := uidgen.NewUidGenerator(1)
+	require.NoError(t, err)
+	for _, id := range []int64{0, 1, 2, math.MaxInt64, ug.NewId(), ug.NewId()} {
+		s, err := p.Encode(id)
+		require.NoError(t, err)
+		got, err := p.Decode(s)
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+
+		o, err := other.Encode(id)
+		require.NoError(t, err)
+		assert.NotEqual(t, s, o)
+	}
+
+	_, err = uidgen.NewPublicIdCodec([]byte("short"), nil)
+	assert.Error(t, err)
+}
+
+func TestStringId(t *testing.T) {
+	type resource struct {
+		ID uidgen.StringId `json:"id"`
+	}
+	b, err := json.Marshal(resource{ID: 1592234567890123456})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"1592234567890123456"}`, string(b))
+
+	for _, in := range []string{`{"id":"1592234567890123456"}`, `{"id":1592234567890123456}`} {
+		var r resource
+		require.NoError(t, json.Unmarshal([]byte(in), &r))
+		assert.Equal(t, uidgen.StringId(1592234567890123456), r.ID)
+	}
+	var r resource
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"abc"}`), &r))
+}
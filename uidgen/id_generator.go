@@ -0,0 +1,18 @@
+package uidgen
+
+import "errors"
+
+// ErrMonotonicOverflow is returned when the ids of a single millisecond are exhausted
+var ErrMonotonicOverflow = errors.New("monotonic id space of the millisecond exhausted")
+
+// IDGenerator is an interface for generating ids of type T
+type IDGenerator[T any] interface {
+	// Generate returns a new id
+	Generate() (T, error)
+}
+
+var (
+	_ IDGenerator[int64] = (*UidGenerator)(nil)
+	_ IDGenerator[ULID]  = (*ULIDGenerator)(nil)
+	_ IDGenerator[UUID]  = (*UUIDv7Generator)(nil)
+)
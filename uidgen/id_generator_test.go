@@ -0,0 +1,120 @@
+package uidgen_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/atong007/kit/uidgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseULID(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		wantMs  int64
+		wantErr bool
+	}{
+		{"success", "01ARZ3NDEKTSV4RRFFQ69G5FAV", 1469922850259, false},
+		{"lower case", "01arz3ndektsv4rrffq69g5fav", 1469922850259, false},
+		{"overflow", "81ARZ3NDEKTSV4RRFFQ69G5FAV", 0, true},
+		{"invalid character", "01ARZ3NDEKTSV4RRFFQ69G5FAU", 0, true},
+		{"invalid length", "01ARZ3NDEK", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := uidgen.ParseULID(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMs, u.Time().UnixMilli())
+			assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", u.String())
+		})
+	}
+}
+
+func TestULIDGenerator_Generate(t *testing.T) {
+	g := uidgen.NewULIDGenerator()
+	var last uidgen.ULID
+	for i := 0; i < 1000; i++ {
+		u, err := g.Generate()
+		require.NoError(t, err)
+		require.Equal(t, 1, bytes.Compare(u[:], last[:]))
+		require.Greater(t, u.String(), last.String())
+		last = u
+	}
+
+	b, err := json.Marshal(struct{ ID uidgen.ULID }{last})
+	require.NoError(t, err)
+	var got struct{ ID uidgen.ULID }
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, last, got.ID)
+
+	var scanned uidgen.ULID
+	v, err := last.Value()
+	require.NoError(t, err)
+	require.NoError(t, scanned.Scan(v))
+	assert.Equal(t, last, scanned)
+
+	require.NoError(t, scanned.Scan(nil))
+	assert.Equal(t, uidgen.ULID{}, scanned)
+}
+
+func TestUUIDv7Generator_Generate(t *testing.T) {
+	g := uidgen.NewUUIDv7Generator()
+	var last uidgen.UUID
+	for i := 0; i < 10000; i++ {
+		u, err := g.Generate()
+		require.NoError(t, err)
+		require.Equal(t, 1, bytes.Compare(u[:], last[:]))
+		require.EqualValues(t, 7, u.Version())
+		require.Equal(t, "RFC4122", u.Variant().String())
+		last = u
+	}
+
+	b, err := json.Marshal(struct{ ID uidgen.UUID }{last})
+	require.NoError(t, err)
+	assert.Equal(t, `{"ID":"`+last.String()+`"}`, string(b))
+	var got struct{ ID uidgen.UUID }
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, last, got.ID)
+
+	var scanned uidgen.UUID
+	v, err := last.Value()
+	require.NoError(t, err)
+	require.NoError(t, scanned.Scan(v))
+	assert.Equal(t, last, scanned)
+	require.NoError(t, scanned.Scan(last[:]))
+	assert.Equal(t, last, scanned)
+	require.NoError(t, scanned.Scan(nil))
+	assert.Equal(t, uidgen.UUID{}, scanned)
+
+	parsed, err := uidgen.ParseUUID(last.String())
+	require.NoError(t, err)
+	assert.Equal(t, last, parsed)
+}
+
+func TestIDGenerator(t *testing.T) {
+	ug, err := uidgen.NewUidGenerator(1)
+	require.NoError(t, err)
+	gens := []func() (interface{}, error){
+		generate[int64](ug),
+		generate[uidgen.ULID](uidgen.NewULIDGenerator()),
+		generate[uidgen.UUID](uidgen.NewUUIDv7Generator()),
+	}
+	for _, gen := range gens {
+		id, err := gen()
+		require.NoError(t, err)
+		assert.NotZero(t, id)
+	}
+}
+
+func generate[T any](g uidgen.IDGenerator[T]) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return g.Generate()
+	}
+}
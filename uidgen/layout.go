@@ -0,0 +1,69 @@
+package uidgen
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Layout is the bit layout of the ids: 1 unused sign bit, then the time since
+// the epoch in TimeUnit, the node id and the sequence within a time unit
+type Layout struct {
+	Epoch    time.Time
+	NodeBits uint8
+	StepBits uint8
+	TimeUnit time.Duration
+}
+
+// DefaultLayout is the layout of the twitter snowflake, 10 node bits and 12
+// sequence bits per millisecond since Nov 04 2010 01:42:54 UTC
+var DefaultLayout = Layout{
+	Epoch:    time.UnixMilli(1288834974657),
+	NodeBits: 10,
+	StepBits: 12,
+	TimeUnit: time.Millisecond,
+}
+
+// TimeBits returns the number of bits holding the time
+func (l Layout) TimeBits() uint8 {
+	return 63 - l.NodeBits - l.StepBits
+}
+
+// MaxNode returns the largest node id
+func (l Layout) MaxNode() int64 {
+	return 1<<l.NodeBits - 1
+}
+
+// Lifetime returns the duration after the epoch during which ids can be generated
+func (l Layout) Lifetime() time.Duration {
+	units := int64(1) << l.TimeBits()
+	if units > int64(1<<63-1)/int64(l.TimeUnit) {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(units) * l.TimeUnit
+}
+
+// Validate checks that the ids fit in 63 bits and that the current time lies
+// within the lifetime of the layout, so that the ids keep growing
+func (l Layout) Validate() error {
+	if l.Epoch.IsZero() {
+		return errors.New("epoch is required")
+	}
+	if l.TimeUnit <= 0 {
+		return fmt.Errorf("invalid time unit: %s", l.TimeUnit)
+	}
+	if l.StepBits == 0 {
+		return errors.New("at least one sequence bit is required")
+	}
+	if int(l.NodeBits)+int(l.StepBits) > 62 {
+		return fmt.Errorf("node bits %d and sequence bits %d leave no bits for the time", l.NodeBits, l.StepBits)
+	}
+	now := time.Now()
+	if now.Before(l.Epoch) {
+		return fmt.Errorf("epoch %s is in the future", l.Epoch)
+	}
+	if end := l.Epoch.Add(l.Lifetime()); !now.Before(end) {
+		return fmt.Errorf("layout has expired at %s", end)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package uidgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/atong007/kit/internal/owner"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// uidNode is a row of the node lease table
+type uidNode struct {
+	NodeId    int64     `gorm:"primaryKey;autoIncrement:false"`
+	Owner     string    `gorm:"size:191;not null;index"`
+	ExpiredAt time.Time `gorm:"type:datetime(3);not null"`
+}
+
+func (uidNode) TableName() string {
+	return "uid_node"
+}
+
+// MySQLNodeAllocator is a NodeAllocator backed by a MySQL lease table
+type MySQLNodeAllocator struct {
+	db *gorm.DB
+}
+
+// NewMySQLNodeAllocator creates a new MySQLNodeAllocator with the db returned by sql.NewMySQL
+func NewMySQLNodeAllocator(db *gorm.DB) *MySQLNodeAllocator {
+	return &MySQLNodeAllocator{db: db}
+}
+
+// Migrate creates the lease table
+func (m *MySQLNodeAllocator) Migrate() error {
+	return m.db.AutoMigrate(&uidNode{})
+}
+
+func (m *MySQLNodeAllocator) Acquire(ctx context.Context, maxNode int64, ttl time.Duration) (NodeLease, error) {
+	holder := owner.New()
+	db := m.db.WithContext(ctx)
+
+	// take over an expired node id first
+	res := db.Exec(
+		"UPDATE uid_node SET owner = ?, expired_at = NOW(3) + INTERVAL ? MICROSECOND "+
+			"WHERE node_id <= ? AND expired_at < NOW(3) ORDER BY node_id LIMIT 1",
+		holder, ttl.Microseconds(), maxNode,
+	)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error acquiring node id: %w", res.Error)
+	}
+
+	// then append a new one, a concurrent append fails with a duplicate key and is retried
+	for i := 0; res.RowsAffected == 0 && i < 3; i++ {
+		res = db.Exec(
+			"INSERT INTO uid_node (node_id, owner, expired_at) "+
+				"SELECT COALESCE(MAX(node_id) + 1, 0), ?, NOW(3) + INTERVAL ? MICROSECOND FROM uid_node "+
+				"HAVING COALESCE(MAX(node_id) + 1, 0) <= ?",
+			holder, ttl.Microseconds(), maxNode,
+		)
+		if res.Error != nil && !isDuplicateEntry(res.Error) {
+			return nil, fmt.Errorf("error acquiring node id: %w", res.Error)
+		}
+		if res.Error == nil && res.RowsAffected == 0 {
+			return nil, ErrNoFreeNode
+		}
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("error acquiring node id after retries: %w", res.Error)
+	}
+
+	var node uidNode
+	if err := db.Where("owner = ?", holder).Take(&node).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoFreeNode
+		}
+		return nil, fmt.Errorf("error reading node id: %w", err)
+	}
+	return &mysqlNodeLease{db: m.db, nodeId: node.NodeId, owner: holder, ttl: ttl}, nil
+}
+
+// isDuplicateEntry reports whether err is a MySQL duplicate entry error
+func isDuplicateEntry(err error) bool {
+	var e *mysql.MySQLError
+	return errors.As(err, &e) && e.Number == 1062
+}
+
+type mysqlNodeLease struct {
+	db     *gorm.DB
+	nodeId int64
+	owner  string
+	ttl    time.Duration
+}
+
+func (l *mysqlNodeLease) NodeId() int64 {
+	return l.nodeId
+}
+
+func (l *mysqlNodeLease) Renew(ctx context.Context) error {
+	res := l.db.WithContext(ctx).Exec(
+		"UPDATE uid_node SET expired_at = NOW(3) + INTERVAL ? MICROSECOND "+
+			"WHERE node_id = ? AND owner = ? AND expired_at >= NOW(3)",
+		l.ttl.Microseconds(), l.nodeId, l.owner,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("error renewing node id %d: %w", l.nodeId, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (l *mysqlNodeLease) Release(ctx context.Context) error {
+	err := l.db.WithContext(ctx).Exec(
+		"UPDATE uid_node SET expired_at = NOW(3) - INTERVAL 1 SECOND WHERE node_id = ? AND owner = ?",
+		l.nodeId, l.owner,
+	).Error
+	if err != nil {
+		return fmt.Errorf("error releasing node id %d: %w", l.nodeId, err)
+	}
+	return nil
+}
+
+var _ NodeAllocator = (*MySQLNodeAllocator)(nil)
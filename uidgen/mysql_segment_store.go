@@ -0,0 +1,90 @@
+package uidgen
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idSegment is a row of the segment table, max_id is the end of the last reserved segment
+type idSegment struct {
+	BizTag    string `gorm:"primaryKey;size:128"`
+	MaxId     int64  `gorm:"not null"`
+	Step      int64  `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (idSegment) TableName() string {
+	return "id_segment"
+}
+
+// MySQLSegmentStore is a SegmentStore backed by a MySQL table
+type MySQLSegmentStore struct {
+	db *gorm.DB
+}
+
+// NewMySQLSegmentStore creates a new MySQLSegmentStore with the db returned by sql.NewMySQL
+func NewMySQLSegmentStore(db *gorm.DB) *MySQLSegmentStore {
+	return &MySQLSegmentStore{db: db}
+}
+
+// Migrate creates the segment table
+func (s *MySQLSegmentStore) Migrate() error {
+	return s.db.AutoMigrate(&idSegment{})
+}
+
+// Register adds the tag whose ids start after start, it is a no-op for a registered tag
+func (s *MySQLSegmentStore) Register(ctx context.Context, tag string, start, step int64) error {
+	if step <= 0 {
+		return fmt.Errorf("invalid step: %d", step)
+	}
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&idSegment{BizTag: tag, MaxId: start, Step: step}).Error
+}
+
+// SetStep changes the size of the next segments of the tag
+func (s *MySQLSegmentStore) SetStep(ctx context.Context, tag string, step int64) error {
+	if step <= 0 {
+		return fmt.Errorf("invalid step: %d", step)
+	}
+	res := s.db.WithContext(ctx).Model(&idSegment{}).Where("biz_tag = ?", tag).Update("step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	// MySQL counts the changed rows, not the matched ones, so setting the same step affects no row
+	var n int64
+	if err := s.db.WithContext(ctx).Model(&idSegment{}).Where("biz_tag = ?", tag).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("unknown biz tag %s", tag)
+	}
+	return nil
+}
+
+func (s *MySQLSegmentStore) NextSegment(ctx context.Context, tag string) (Segment, error) {
+	var seg idSegment
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec("UPDATE id_segment SET max_id = max_id + step, updated_at = NOW() WHERE biz_tag = ?", tag)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("unknown biz tag %s", tag)
+		}
+		return tx.Where("biz_tag = ?", tag).Take(&seg).Error
+	})
+	if err != nil {
+		return Segment{}, err
+	}
+	return Segment{Start: seg.MaxId - seg.Step, End: seg.MaxId}, nil
+}
+
+var _ SegmentStore = (*MySQLSegmentStore)(nil)
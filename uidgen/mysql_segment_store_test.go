@@ -0,0 +1,39 @@
+package uidgen_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atong007/kit/sql/sqltest"
+	"github.com/atong007/kit/uidgen"
+)
+
+func TestMySQLSegmentStore(t *testing.T) {
+	if os.Getenv(sqltest.MySQLDSNEnv) == "" {
+		t.Skipf("%s is not set", sqltest.MySQLDSNEnv)
+	}
+	store := uidgen.NewMySQLSegmentStore(sqltest.Open(t))
+	require.NoError(t, store.Migrate())
+	ctx := context.Background()
+	// the rows are kept across the runs
+	tag := "test-" + uuid.New().String()
+
+	require.NoError(t, store.Register(ctx, tag, 100, 10))
+	seg, err := store.NextSegment(ctx, tag)
+	require.NoError(t, err)
+	assert.Equal(t, uidgen.Segment{Start: 100, End: 110}, seg)
+
+	// setting the current step changes no row
+	require.NoError(t, store.SetStep(ctx, tag, 10))
+	require.NoError(t, store.SetStep(ctx, tag, 20))
+	seg, err = store.NextSegment(ctx, tag)
+	require.NoError(t, err)
+	assert.Equal(t, uidgen.Segment{Start: 110, End: 130}, seg)
+
+	assert.Error(t, store.SetStep(ctx, "unknown-"+tag, 10))
+}
@@ -0,0 +1,167 @@
+package uidgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSegmentExhausted is returned when the next segment could not be loaded in time
+var ErrSegmentExhausted = errors.New("id segment exhausted")
+
+// Segment is a reserved id range (Start, End] of a business tag
+type Segment struct {
+	Start int64
+	End   int64
+}
+
+// SegmentStore reserves the id segments of the business tags
+type SegmentStore interface {
+	// NextSegment reserves the next segment of the tag
+	NextSegment(ctx context.Context, tag string) (Segment, error)
+}
+
+// SegmentAllocator hands out dense and strictly increasing ids per business
+// tag from the segments reserved in a SegmentStore. The next segment of a tag
+// is prefetched in the background once the current one is consumed past the
+// prefetch ratio, so that the store is off the hot path.
+type SegmentAllocator struct {
+	store    SegmentStore
+	prefetch float64
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	buffers map[string]*segmentBuffer
+}
+
+type segmentBuffer struct {
+	mu      sync.Mutex
+	current Segment
+	next    int64
+	// the prefetched segment, valid if ready is true
+	ahead    Segment
+	ready    bool
+	loading  bool
+	loadErr  error
+	loadDone chan struct{}
+}
+
+// NewSegmentAllocator creates a new SegmentAllocator over the store, the next
+// segment is prefetched once 10% of the current one has been handed out
+func NewSegmentAllocator(store SegmentStore) *SegmentAllocator {
+	return &SegmentAllocator{
+		store:    store,
+		prefetch: 0.1,
+		timeout:  time.Second * 5,
+		buffers:  map[string]*segmentBuffer{},
+	}
+}
+
+// SetPrefetchRatio sets the consumed ratio of the current segment at which the next one is loaded
+func (a *SegmentAllocator) SetPrefetchRatio(r float64) *SegmentAllocator {
+	a.prefetch = r
+	return a
+}
+
+// Next returns the next id of the tag
+func (a *SegmentAllocator) Next(ctx context.Context, tag string) (int64, error) {
+	a.mu.Lock()
+	buf, ok := a.buffers[tag]
+	if !ok {
+		buf = &segmentBuffer{}
+		a.buffers[tag] = buf
+	}
+	a.mu.Unlock()
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	for {
+		if buf.next < buf.current.End {
+			buf.next++
+			a.maybePrefetch(buf, tag)
+			return buf.next, nil
+		}
+		if buf.ready {
+			buf.current, buf.next, buf.ready = buf.ahead, buf.ahead.Start, false
+			continue
+		}
+		if !buf.loading {
+			a.load(buf, tag)
+		}
+		if err := a.waitLoad(ctx, buf); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Generator returns an IDGenerator of the ids of the tag
+func (a *SegmentAllocator) Generator(tag string) IDGenerator[int64] {
+	return segmentGenerator{alloc: a, tag: tag}
+}
+
+func (a *SegmentAllocator) maybePrefetch(buf *segmentBuffer, tag string) {
+	if buf.ready || buf.loading {
+		return
+	}
+	size := buf.current.End - buf.current.Start
+	if float64(buf.next-buf.current.Start) >= float64(size)*a.prefetch {
+		a.load(buf, tag)
+	}
+}
+
+// load starts loading the next segment in the background, buf.mu must be held
+func (a *SegmentAllocator) load(buf *segmentBuffer, tag string) {
+	buf.loading = true
+	buf.loadErr = nil
+	done := make(chan struct{})
+	buf.loadDone = done
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+		defer cancel()
+		seg, err := a.store.NextSegment(ctx, tag)
+		if err == nil && seg.End <= seg.Start {
+			err = fmt.Errorf("invalid segment (%d, %d]", seg.Start, seg.End)
+		}
+
+		buf.mu.Lock()
+		buf.loading = false
+		if err != nil {
+			buf.loadErr = fmt.Errorf("error loading segment of %s: %w", tag, err)
+		} else {
+			buf.ahead, buf.ready = seg, true
+		}
+		buf.mu.Unlock()
+		close(done)
+	}()
+}
+
+// waitLoad waits for the running load, buf.mu must be held and is released while waiting
+func (a *SegmentAllocator) waitLoad(ctx context.Context, buf *segmentBuffer) error {
+	done := buf.loadDone
+	buf.mu.Unlock()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %v", ErrSegmentExhausted, ctx.Err())
+	}
+	buf.mu.Lock()
+	if err != nil {
+		return err
+	}
+	if !buf.ready && !buf.loading && buf.loadErr != nil {
+		return buf.loadErr
+	}
+	return nil
+}
+
+type segmentGenerator struct {
+	alloc *SegmentAllocator
+	tag   string
+}
+
+func (g segmentGenerator) Generate() (int64, error) {
+	return g.alloc.Next(context.Background(), g.tag)
+}
@@ -0,0 +1,82 @@
+package uidgen_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/atong007/kit/uidgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memSegmentStore struct {
+	mu    sync.Mutex
+	maxId map[string]int64
+	step  int64
+	calls int
+	err   error
+}
+
+func (s *memSegmentStore) NextSegment(ctx context.Context, tag string) (uidgen.Segment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return uidgen.Segment{}, s.err
+	}
+	start := s.maxId[tag]
+	s.maxId[tag] = start + s.step
+	return uidgen.Segment{Start: start, End: start + s.step}, nil
+}
+
+func TestSegmentAllocator_Next(t *testing.T) {
+	store := &memSegmentStore{maxId: map[string]int64{"order": 1000}, step: 10}
+	alloc := uidgen.NewSegmentAllocator(store)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := map[int64]bool{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				id, err := alloc.Next(ctx, "order")
+				assert.NoError(t, err)
+				mu.Lock()
+				ids[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, ids, 200)
+	for id := int64(1001); id <= 1200; id++ {
+		assert.True(t, ids[id], "missing id %d", id)
+	}
+
+	gen := alloc.Generator("user")
+	first, err := gen.Generate()
+	require.NoError(t, err)
+	second, err := gen.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, []int64{first, second})
+}
+
+func TestSegmentAllocator_StoreError(t *testing.T) {
+	store := &memSegmentStore{maxId: map[string]int64{}, step: 10, err: errors.New("db down")}
+	alloc := uidgen.NewSegmentAllocator(store)
+	_, err := alloc.Next(context.Background(), "order")
+	assert.Error(t, err)
+
+	store.mu.Lock()
+	store.err = nil
+	store.mu.Unlock()
+	id, err := alloc.Next(context.Background(), "order")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+}
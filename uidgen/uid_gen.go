@@ -1,22 +1,293 @@
 package uidgen
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/bwmarrin/snowflake"
+	"sync"
+	"time"
 )
 
+// ErrClosed is returned by Generate after Close
+var ErrClosed = errors.New("generator has been closed")
+
 type UidGenerator struct {
-	node *snowflake.Node
+	mu       sync.Mutex
+	layout   Layout
+	nodeId   int64
+	now      func() time.Time
+	epoch    time.Time
+	lastTime int64
+	sequence int64
+
+	// the rollback handling, see ClockPolicy and WithStateFile
+	clockPolicy ClockPolicy
+	rollbacks   int64
+	stateFile   string
+	stateWindow time.Duration
+	persisted   int64
+	// the rollback count of the state file
+	persistedRollbacks int64
+	// closed when the state file write in progress is done
+	persisting chan struct{}
+
+	// the node id lease of the generators created by NewLeasedUidGenerator
+	lease         NodeLease
+	leaseDeadline time.Time
+	leaseErr      error
+	stopRenew     context.CancelFunc
+	renewDone     chan struct{}
+}
+
+// IdInfo holds the components of an id
+type IdInfo struct {
+	Time     time.Time
+	Node     int64
+	Sequence int64
 }
 
-func NewUidGenerator(nodeId int64) (*UidGenerator, error) {
-	node, err := snowflake.NewNode(nodeId)
+// Option configures a UidGenerator
+type Option func(ug *UidGenerator)
+
+// WithLayout sets the bit layout of the generator, DefaultLayout is used otherwise
+func WithLayout(l Layout) Option {
+	return func(ug *UidGenerator) {
+		ug.layout = l
+	}
+}
+
+func NewUidGenerator(nodeId int64, opts ...Option) (*UidGenerator, error) {
+	ug, err := newUidGenerator(opts)
+	if err != nil {
+		return nil, err
+	}
+	if nodeId < 0 || nodeId > ug.layout.MaxNode() {
+		return nil, fmt.Errorf("error generating new node: node id must be between 0 and %d", ug.layout.MaxNode())
+	}
+	ug.nodeId = nodeId
+	return ug, nil
+}
+
+// NewLeasedUidGenerator creates a new UidGenerator with a node id leased from
+// the allocator. The lease is renewed every ttl/3 in the background, the
+// generator stops generating ids once the lease is lost or Close is called.
+func NewLeasedUidGenerator(ctx context.Context, alloc NodeAllocator, ttl time.Duration, opts ...Option) (*UidGenerator, error) {
+	ug, err := newUidGenerator(opts)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	lease, err := alloc.Acquire(ctx, ug.layout.MaxNode(), ttl)
 	if err != nil {
-		return nil, fmt.Errorf("error generating new node: %w", err)
+		return nil, fmt.Errorf("error acquiring node id: %w", err)
+	}
+	if lease.NodeId() < 0 || lease.NodeId() > ug.layout.MaxNode() {
+		_ = lease.Release(ctx)
+		return nil, fmt.Errorf("error acquiring node id: node id %d is out of range", lease.NodeId())
+	}
+	ug.nodeId = lease.NodeId()
+	ug.lease = lease
+	ug.leaseDeadline = start.Add(ttl)
+
+	renewCtx, cancel := context.WithCancel(context.Background())
+	ug.stopRenew = cancel
+	ug.renewDone = make(chan struct{})
+	go ug.renew(renewCtx, ttl)
+	return ug, nil
+}
+
+func newUidGenerator(opts []Option) (*UidGenerator, error) {
+	ug := &UidGenerator{layout: DefaultLayout, clockPolicy: DefaultClockPolicy, now: time.Now}
+	for _, opt := range opts {
+		opt(ug)
+	}
+	if err := ug.layout.Validate(); err != nil {
+		return nil, fmt.Errorf("error validating layout: %w", err)
+	}
+	if ug.clockPolicy.ReservedBits >= ug.layout.StepBits {
+		return nil, fmt.Errorf("reserved bits %d must be less than sequence bits %d", ug.clockPolicy.ReservedBits, ug.layout.StepBits)
+	}
+	// keep the monotonic clock reading of now in the epoch, so that the wall
+	// clock steps don't move the time of the ids backwards
+	now := ug.now()
+	ug.epoch = now.Add(ug.layout.Epoch.Sub(now))
+	if ug.stateFile != "" {
+		if err := ug.restoreState(); err != nil {
+			return nil, err
+		}
+	}
+	return ug, nil
+}
+
+// renew renews the lease until ctx is done or the lease is lost.
+// A failed renewal is retried, the lease stays valid until its deadline.
+func (ug *UidGenerator) renew(ctx context.Context, ttl time.Duration) {
+	defer close(ug.renewDone)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		err := ug.lease.Renew(ctx)
+		ug.mu.Lock()
+		if err == nil {
+			ug.leaseDeadline = start.Add(ttl)
+		} else if errors.Is(err, ErrLeaseLost) {
+			ug.leaseErr = ErrLeaseLost
+		}
+		ug.mu.Unlock()
+		if errors.Is(err, ErrLeaseLost) {
+			return
+		}
+	}
+}
+
+// NodeId returns the node id of the generator
+func (ug *UidGenerator) NodeId() int64 {
+	return ug.nodeId
+}
+
+// Close stops renewing and releases the node id lease, it is a no-op for the
+// generators not created by NewLeasedUidGenerator
+func (ug *UidGenerator) Close(ctx context.Context) error {
+	if ug.lease == nil {
+		return nil
+	}
+	ug.stopRenew()
+	<-ug.renewDone
+
+	ug.mu.Lock()
+	lost := ug.leaseErr != nil
+	ug.leaseErr = ErrClosed
+	ug.mu.Unlock()
+	if lost {
+		return nil
 	}
-	return &UidGenerator{node: node}, nil
+	return ug.lease.Release(ctx)
 }
 
+// Layout returns the bit layout of the generator
+func (ug *UidGenerator) Layout() Layout {
+	return ug.layout
+}
+
+// NewId returns a new id, it panics if the node id lease has been lost, or if
+// the clock set by WithClock or the state of WithStateFile is ahead of the
+// clock by more than the ClockPolicy allows.
+//
+// Deprecated: use Generate, which returns these errors.
 func (ug *UidGenerator) NewId() int64 {
-	return ug.node.Generate().Int64()
+	id, err := ug.Generate()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Generate returns a new id
+func (ug *UidGenerator) Generate() (int64, error) {
+	ug.mu.Lock()
+	defer ug.mu.Unlock()
+
+	for {
+		if ug.lease != nil {
+			if ug.leaseErr != nil {
+				return 0, ug.leaseErr
+			}
+			if !time.Now().Before(ug.leaseDeadline) {
+				return 0, ErrLeaseLost
+			}
+		}
+
+		l := ug.layout
+		sequenceBits := l.StepBits - ug.clockPolicy.ReservedBits
+		now := ug.since()
+		if now < ug.lastTime {
+			var err error
+			if now, err = ug.rollback(now); err != nil {
+				return 0, err
+			}
+		}
+		var sequence int64
+		if now == ug.lastTime {
+			sequence = (ug.sequence + 1) & (1<<sequenceBits - 1)
+			if sequence == 0 {
+				for now <= ug.lastTime {
+					time.Sleep(l.TimeUnit / 10)
+					now = ug.since()
+				}
+			}
+		}
+		if ug.mustPersist(now) {
+			// the lock is released during the write, start over with the current state
+			if err := ug.persist(now); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		ug.lastTime, ug.sequence = now, sequence
+		step := ug.rollbacks<<sequenceBits | sequence
+		return now<<(l.NodeBits+l.StepBits) | ug.nodeId<<l.StepBits | step, nil
+	}
+}
+
+// since returns the time units elapsed since the epoch, on the monotonic clock
+// unless WithClock is used. The wall clock is only compared with the state file.
+func (ug *UidGenerator) since() int64 {
+	d := ug.now().Sub(ug.epoch)
+	if d < 0 {
+		return 0
+	}
+	return int64(d / ug.layout.TimeUnit)
+}
+
+// Decompose returns the creation time, node id and sequence of the id
+func (ug *UidGenerator) Decompose(id int64) IdInfo {
+	l := ug.layout
+	units := id >> (l.NodeBits + l.StepBits)
+	return IdInfo{
+		Time:     l.Epoch.Add(time.Duration(units) * l.TimeUnit).Local(),
+		Node:     id >> l.StepBits & l.MaxNode(),
+		Sequence: id & (1<<l.StepBits - 1),
+	}
+}
+
+// MinId returns the smallest id which can be generated at t
+func (ug *UidGenerator) MinId(t time.Time) int64 {
+	units, ok := ug.units(t)
+	if !ok {
+		return 0
+	}
+	return units << (ug.layout.NodeBits + ug.layout.StepBits)
+}
+
+// MaxId returns the largest id which can be generated at t
+func (ug *UidGenerator) MaxId(t time.Time) int64 {
+	units, ok := ug.units(t)
+	if !ok {
+		return -1
+	}
+	shift := ug.layout.NodeBits + ug.layout.StepBits
+	return units<<shift | (1<<shift - 1)
+}
+
+// units returns the time units between the epoch and t, false if t is before the epoch
+func (ug *UidGenerator) units(t time.Time) (int64, bool) {
+	if t.Before(ug.layout.Epoch) {
+		return 0, false
+	}
+	return int64(t.Sub(ug.layout.Epoch) / ug.layout.TimeUnit), true
+}
+
+// IdRange returns the bounds of the ids generated in [from, to], for
+// querying the records created in a time range by their id:
+//
+//	min, max := ug.IdRange(from, to)
+//	db.Where("id BETWEEN ? AND ?", min, max)
+func (ug *UidGenerator) IdRange(from, to time.Time) (min, max int64) {
+	return ug.MinId(from), ug.MaxId(to)
 }
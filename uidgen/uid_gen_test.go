@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestNewUidGenerator(t *testing.T) {
@@ -61,3 +62,98 @@ func TestUidGenerator_NewId(t *testing.T) {
 		})
 	}
 }
+
+func TestUidGenerator_Decompose(t *testing.T) {
+	ug, err := uidgen.NewUidGenerator(111)
+	require.NoError(t, err)
+
+	before := time.Now().Truncate(time.Millisecond)
+	id := ug.NewId()
+	after := time.Now()
+
+	info := ug.Decompose(id)
+	assert.Equal(t, int64(111), info.Node)
+	assert.GreaterOrEqual(t, info.Sequence, int64(0))
+	assert.False(t, info.Time.Before(before))
+	assert.False(t, info.Time.After(after))
+}
+
+func TestUidGenerator_IdRange(t *testing.T) {
+	ug, err := uidgen.NewUidGenerator(111)
+	require.NoError(t, err)
+
+	from := time.Now()
+	id := ug.NewId()
+	to := time.Now()
+
+	tests := []struct {
+		name   string
+		from   time.Time
+		to     time.Time
+		wantIn bool
+	}{
+		{"in range", from, to, true},
+		{"before range", to.Add(time.Millisecond), to.Add(time.Hour), false},
+		{"after range", from.Add(-time.Hour), from.Add(-time.Millisecond), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			min, max := ug.IdRange(tt.from, tt.to)
+			assert.Equal(t, tt.wantIn, id >= min && id <= max)
+		})
+	}
+	assert.Equal(t, uidgen.IdInfo{Time: from.Truncate(time.Millisecond)}, ug.Decompose(ug.MinId(from)))
+}
+
+func TestLayout_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  uidgen.Layout
+		wantErr bool
+	}{
+		{"default", uidgen.DefaultLayout, false},
+		{
+			"10ms tick",
+			uidgen.Layout{Epoch: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), NodeBits: 16, StepBits: 8, TimeUnit: time.Millisecond * 10},
+			false,
+		},
+		{"missing epoch", uidgen.Layout{NodeBits: 10, StepBits: 12, TimeUnit: time.Millisecond}, true},
+		{"epoch in the future", uidgen.Layout{Epoch: time.Now().Add(time.Hour), NodeBits: 10, StepBits: 12, TimeUnit: time.Millisecond}, true},
+		{"too many bits", uidgen.Layout{Epoch: uidgen.DefaultLayout.Epoch, NodeBits: 32, StepBits: 31, TimeUnit: time.Millisecond}, true},
+		{"expired", uidgen.Layout{Epoch: uidgen.DefaultLayout.Epoch, NodeBits: 20, StepBits: 20, TimeUnit: time.Millisecond}, true},
+		{"no sequence bits", uidgen.Layout{Epoch: uidgen.DefaultLayout.Epoch, NodeBits: 10, TimeUnit: time.Millisecond}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.layout.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUidGenerator_WithLayout(t *testing.T) {
+	layout := uidgen.Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NodeBits: 4,
+		StepBits: 2,
+		TimeUnit: time.Millisecond * 10,
+	}
+	ug, err := uidgen.NewUidGenerator(15, uidgen.WithLayout(layout))
+	require.NoError(t, err)
+	_, err = uidgen.NewUidGenerator(16, uidgen.WithLayout(layout))
+	assert.Error(t, err)
+
+	var last int64
+	for i := 0; i < 20; i++ {
+		id := ug.NewId()
+		require.Greater(t, id, last)
+		last = id
+		info := ug.Decompose(id)
+		assert.Equal(t, int64(15), info.Node)
+		assert.WithinDuration(t, time.Now(), info.Time, layout.TimeUnit*2)
+	}
+}
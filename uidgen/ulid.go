@@ -0,0 +1,180 @@
+package uidgen
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var crockfordIndex = func() [256]byte {
+	var idx [256]byte
+	for i := range idx {
+		idx[i] = 0xFF
+	}
+	for i := 0; i < len(crockford); i++ {
+		idx[crockford[i]] = byte(i)
+		idx[crockford[i]|0x20] = byte(i)
+	}
+	return idx
+}()
+
+// ErrInvalidULID is returned when parsing a malformed ULID
+var ErrInvalidULID = errors.New("invalid ulid")
+
+// ULID is a lexicographically sortable id of a 48 bits millisecond timestamp
+// and 80 random bits, encoded as 26 characters of Crockford's base32.
+// It is stored as char(26) by gorm and marshalled as a JSON string.
+type ULID [16]byte
+
+// ParseULID parses the string form of a ULID
+func ParseULID(s string) (ULID, error) {
+	var u ULID
+	if len(s) != 26 || crockfordIndex[s[0]] > 7 {
+		return u, ErrInvalidULID
+	}
+	// decode the 130 bits, the first character only holds 3 bits
+	var carry uint16
+	var bits uint
+	n := 15
+	for i := len(s) - 1; i >= 0; i-- {
+		v := crockfordIndex[s[i]]
+		if v == 0xFF {
+			return ULID{}, ErrInvalidULID
+		}
+		carry |= uint16(v) << bits
+		bits += 5
+		if bits >= 8 && n >= 0 {
+			u[n] = byte(carry)
+			n--
+			carry >>= 8
+			bits -= 8
+		}
+	}
+	return u, nil
+}
+
+// String returns the 26 characters form of the ULID
+func (u ULID) String() string {
+	b := make([]byte, 26)
+	var carry uint16
+	var bits uint
+	n := 25
+	for i := 15; i >= 0; i-- {
+		carry |= uint16(u[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			b[n] = crockford[carry&0x1F]
+			n--
+			carry >>= 5
+			bits -= 5
+		}
+	}
+	b[0] = crockford[carry&0x1F]
+	return string(b)
+}
+
+// Time returns the creation time of the ULID
+func (u ULID) Time() time.Time {
+	var ms int64
+	for i := 0; i < 6; i++ {
+		ms = ms<<8 | int64(u[i])
+	}
+	return time.UnixMilli(ms)
+}
+
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *ULID) UnmarshalText(b []byte) error {
+	parsed, err := ParseULID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// Value implements driver.Valuer
+func (u ULID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// Scan implements sql.Scanner, both the string and the 16 bytes forms are
+// accepted and NULL is the zero ULID
+func (u *ULID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*u = ULID{}
+		return nil
+	case string:
+		return u.UnmarshalText([]byte(v))
+	case []byte:
+		if len(v) == 16 {
+			copy(u[:], v)
+			return nil
+		}
+		return u.UnmarshalText(v)
+	}
+	return fmt.Errorf("unsupported type %T for ulid", src)
+}
+
+// GormDBDataType returns the column type of the ULID
+func (ULID) GormDBDataType(*gorm.DB, *schema.Field) string {
+	return "char(26)"
+}
+
+// ULIDGenerator generates ULIDs which are monotonic within a millisecond:
+// the random part of the previous ULID is incremented
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entropy io.Reader
+	lastMs  int64
+	last    ULID
+}
+
+// NewULIDGenerator creates a new ULIDGenerator
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{now: time.Now, entropy: rand.Reader}
+}
+
+// Generate returns a new ULID, ErrMonotonicOverflow is returned when the 80
+// random bits of the millisecond are exhausted
+func (g *ULIDGenerator) Generate() (ULID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().UnixMilli()
+	// a clock moved backwards keeps the last millisecond to stay monotonic
+	if ms <= g.lastMs {
+		u := g.last
+		for i := 15; i >= 6; i-- {
+			u[i]++
+			if u[i] != 0 {
+				g.last = u
+				return u, nil
+			}
+		}
+		return ULID{}, ErrMonotonicOverflow
+	}
+
+	var u ULID
+	for i := 0; i < 6; i++ {
+		u[i] = byte(ms >> (40 - 8*i))
+	}
+	if _, err := io.ReadFull(g.entropy, u[6:]); err != nil {
+		return ULID{}, fmt.Errorf("error reading entropy: %w", err)
+	}
+	g.lastMs, g.last = ms, u
+	return u, nil
+}
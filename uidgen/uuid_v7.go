@@ -0,0 +1,114 @@
+package uidgen
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// UUID is a uuid.UUID which is stored as char(36) by gorm and marshalled as a JSON string
+type UUID uuid.UUID
+
+// ParseUUID parses the string forms of a UUID accepted by uuid.Parse
+func ParseUUID(s string) (UUID, error) {
+	u, err := uuid.Parse(s)
+	return UUID(u), err
+}
+
+// String returns the 36 characters form of the UUID
+func (u UUID) String() string {
+	return uuid.UUID(u).String()
+}
+
+// Version returns the version of the UUID
+func (u UUID) Version() uuid.Version {
+	return uuid.UUID(u).Version()
+}
+
+// Variant returns the variant of the UUID
+func (u UUID) Variant() uuid.Variant {
+	return uuid.UUID(u).Variant()
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return uuid.UUID(u).MarshalText()
+}
+
+func (u *UUID) UnmarshalText(b []byte) error {
+	return (*uuid.UUID)(u).UnmarshalText(b)
+}
+
+// Value implements driver.Valuer, the UUID is stored in its string form
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// Scan implements sql.Scanner, both the string and the 16 bytes forms are
+// accepted and NULL is the zero UUID
+func (u *UUID) Scan(src interface{}) error {
+	if src == nil {
+		*u = UUID{}
+		return nil
+	}
+	return (*uuid.UUID)(u).Scan(src)
+}
+
+// GormDBDataType returns the column type of the UUID
+func (UUID) GormDBDataType(*gorm.DB, *schema.Field) string {
+	return "char(36)"
+}
+
+// UUIDv7Generator generates the time ordered version 7 UUIDs of RFC 9562.
+// The 12 bits following the millisecond timestamp are a counter seeded
+// randomly every millisecond, so the UUIDs are monotonic within a millisecond.
+type UUIDv7Generator struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entropy io.Reader
+	lastMs  int64
+	counter uint16
+}
+
+// NewUUIDv7Generator creates a new UUIDv7Generator
+func NewUUIDv7Generator() *UUIDv7Generator {
+	return &UUIDv7Generator{now: time.Now, entropy: rand.Reader}
+}
+
+// Generate returns a new UUIDv7. When the counter of a millisecond is
+// exhausted the timestamp is advanced by one millisecond.
+func (g *UUIDv7Generator) Generate() (UUID, error) {
+	var u UUID
+	if _, err := io.ReadFull(g.entropy, u[6:]); err != nil {
+		return u, fmt.Errorf("error reading entropy: %w", err)
+	}
+
+	g.mu.Lock()
+	ms := g.now().UnixMilli()
+	if ms > g.lastMs {
+		// seed with the most significant bit cleared to leave room for increments
+		g.lastMs = ms
+		g.counter = binary.BigEndian.Uint16(u[6:8]) & 0x07FF
+	} else {
+		// a clock moved backwards keeps the last millisecond to stay monotonic
+		g.counter++
+		if g.counter > 0x0FFF {
+			g.lastMs++
+			g.counter = 0
+		}
+	}
+	ms, counter := g.lastMs, g.counter
+	g.mu.Unlock()
+
+	binary.BigEndian.PutUint64(u[0:8], uint64(ms)<<16|uint64(counter))
+	u[6] = 0x70 | u[6]&0x0F
+	u[8] = 0x80 | u[8]&0x3F
+	return u, nil
+}
@@ -40,7 +40,7 @@ func init() {
 	// 验证器注册翻译器
 	err = zhTrans.RegisterDefaultTranslations(val, trans)
 	if err != nil {
-		log.Fatalf("error registering default translation for validator: %w", err)
+		log.Fatalf("error registering default translation for validator: %v", err)
 	}
 }
 
@@ -0,0 +1,310 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/atong007/kit/log"
+)
+
+// ErrBreakerOpen is returned for the requests rejected by an open circuit breaker
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+// The states of a circuit breaker
+const (
+	// StateClosed lets the calls through and records their outcomes
+	StateClosed BreakerState = iota
+	// StateOpen rejects the calls until the open timeout elapses
+	StateOpen
+	// StateHalfOpen lets a few trial calls through to decide whether to close or open again
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerConfig configures the circuit breakers, the zero values are replaced by the defaults
+type BreakerConfig struct {
+	// WindowSize is the number of the last calls the rates are computed on, 20 by default
+	WindowSize int
+	// MinCalls is the number of calls needed to compute the rates, 10 by default
+	MinCalls int
+	// FailureRate opens the breaker when reached, 0.5 by default
+	FailureRate float64
+	// SlowCallDuration is the duration above which a call is slow, the slow calls are not counted if 0
+	SlowCallDuration time.Duration
+	// SlowCallRate opens the breaker when reached, 0.5 by default
+	SlowCallRate float64
+	// OpenTimeout is the duration of the open state, 30s by default
+	OpenTimeout time.Duration
+	// HalfOpenCalls is the number of successful trial calls closing the breaker, 3 by default
+	HalfOpenCalls int
+	// IsFailure reports whether a call failed, the errors and 5xx responses by default
+	IsFailure func(resp *http.Response, err error) bool
+	// Logger receives the state changes
+	Logger log.Logger
+	// OnStateChange is called after every state change
+	OnStateChange func(name string, from, to BreakerState)
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.WindowSize <= 0 {
+		c.WindowSize = 20
+	}
+	if c.MinCalls <= 0 {
+		c.MinCalls = 10
+	}
+	if c.MinCalls > c.WindowSize {
+		c.MinCalls = c.WindowSize
+	}
+	if c.FailureRate <= 0 {
+		c.FailureRate = 0.5
+	}
+	if c.SlowCallRate <= 0 {
+		c.SlowCallRate = 0.5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = time.Second * 30
+	}
+	if c.HalfOpenCalls <= 0 {
+		c.HalfOpenCalls = 3
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		}
+	}
+	return c
+}
+
+// outcome is the outcome of a call in the window
+type outcome struct {
+	failure bool
+	slow    bool
+}
+
+// Breaker is a circuit breaker over the sliding window of the last calls
+type Breaker struct {
+	name string
+	conf BreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	openedAt time.Time
+	// generation is bumped at every state change, the outcomes of the calls of the former states are ignored
+	generation uint64
+	window     []outcome
+	next       int
+	// the trial calls of the half-open state
+	trials    int
+	successes int
+}
+
+// NewBreaker creates a new closed Breaker
+func NewBreaker(name string, conf BreakerConfig) *Breaker {
+	conf = conf.withDefaults()
+	return &Breaker{name: name, conf: conf, now: time.Now, window: make([]outcome, 0, conf.WindowSize)}
+}
+
+// Name returns the name of the breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkTimeout()
+	return b.state
+}
+
+// Allow reports whether a call may be made, done must be called with the outcome of the allowed call
+func (b *Breaker) Allow() (done func(resp *http.Response, err error, elapsed time.Duration), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkTimeout()
+	switch b.state {
+	case StateOpen:
+		return nil, fmt.Errorf("%w: %s", ErrBreakerOpen, b.name)
+	case StateHalfOpen:
+		if b.trials >= b.conf.HalfOpenCalls {
+			return nil, fmt.Errorf("%w: %s", ErrBreakerOpen, b.name)
+		}
+		b.trials++
+	}
+	generation := b.generation
+	return func(resp *http.Response, err error, elapsed time.Duration) {
+		b.record(generation, outcome{
+			failure: b.conf.IsFailure(resp, err),
+			slow:    b.conf.SlowCallDuration > 0 && elapsed > b.conf.SlowCallDuration,
+		})
+	}, nil
+}
+
+// Do makes the call of send through the breaker
+func (b *Breaker) Do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	done, err := b.Allow()
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	start := b.now()
+	resp, err := send(req)
+	done(resp, err, b.now().Sub(start))
+	return resp, err
+}
+
+func (b *Breaker) record(generation uint64, o outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case StateClosed:
+		if len(b.window) < b.conf.WindowSize {
+			b.window = append(b.window, o)
+		} else {
+			b.window[b.next] = o
+		}
+		b.next = (b.next + 1) % b.conf.WindowSize
+		if len(b.window) < b.conf.MinCalls {
+			return
+		}
+		var failures, slows int
+		for _, w := range b.window {
+			if w.failure {
+				failures++
+			}
+			if w.slow {
+				slows++
+			}
+		}
+		n := float64(len(b.window))
+		if float64(failures)/n >= b.conf.FailureRate || float64(slows)/n >= b.conf.SlowCallRate {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if o.failure || o.slow {
+			b.setState(StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.conf.HalfOpenCalls {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// checkTimeout moves an open breaker to half-open after the open timeout, b.mu must be held
+func (b *Breaker) checkTimeout() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.conf.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+}
+
+// setState resets the counters of the new state, b.mu must be held
+func (b *Breaker) setState(state BreakerState) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.window, b.next = b.window[:0], 0
+	b.trials, b.successes = 0, 0
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+	if b.conf.Logger != nil {
+		if state == StateOpen {
+			b.conf.Logger.Errorf("circuit breaker %s changed from %s to %s", b.name, from, state)
+		} else {
+			b.conf.Logger.Infof("circuit breaker %s changed from %s to %s", b.name, from, state)
+		}
+	}
+	if b.conf.OnStateChange != nil {
+		// called in a goroutine, so that the callback may use the breaker
+		go b.conf.OnStateChange(b.name, from, state)
+	}
+}
+
+// Breakers holds a Breaker per host, or per key of the key func
+type Breakers struct {
+	conf BreakerConfig
+	key  func(req *http.Request) string
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewBreakers creates new Breakers keyed by the hosts of the requests
+func NewBreakers(conf BreakerConfig) *Breakers {
+	return &Breakers{
+		conf:     conf,
+		key:      func(req *http.Request) string { return req.URL.Host },
+		breakers: map[string]*Breaker{},
+	}
+}
+
+// SetKeyFunc sets the key of the breaker of a request, such as its host and path for a breaker per endpoint
+func (bs *Breakers) SetKeyFunc(fn func(req *http.Request) string) *Breakers {
+	bs.key = fn
+	return bs
+}
+
+// Get returns the breaker of the key, it is created if needed
+func (bs *Breakers) Get(key string) *Breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.breakers[key]
+	if !ok {
+		b = NewBreaker(key, bs.conf)
+		bs.breakers[key] = b
+	}
+	return b
+}
+
+// Do makes the call of send through the breaker of the request
+func (bs *Breakers) Do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	return bs.Get(bs.key(req)).Do(req, send)
+}
+
+// RoundTripper wraps next, the requests are rejected with ErrBreakerOpen while their breaker is open.
+// http.DefaultTransport is used if next is nil.
+func (bs *Breakers) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return bs.Do(req, next.RoundTrip)
+	})
+}
+
+// RoundTripperFunc is a function implementing http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeBody closes the body of a request which is not sent, as required of a RoundTripper
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
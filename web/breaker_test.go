@@ -0,0 +1,197 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *recordingLogger) Debug(args ...interface{}) {}
+func (l *recordingLogger) Info(args ...interface{})  {}
+func (l *recordingLogger) Error(args ...interface{}) {}
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.Infof(format, args...)
+}
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.Infof(format, args...)
+}
+
+func call(b *Breaker, code int, elapsed time.Duration) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	done(&http.Response{StatusCode: code}, nil, elapsed)
+	return nil
+}
+
+func TestBreaker(t *testing.T) {
+	logger := &recordingLogger{}
+	now := time.Now()
+	b := NewBreaker("api", BreakerConfig{
+		WindowSize:    4,
+		MinCalls:      4,
+		OpenTimeout:   time.Second,
+		HalfOpenCalls: 2,
+		Logger:        logger,
+	})
+	b.now = func() time.Time { return now }
+
+	// 1 failure out of 4 calls keeps it closed
+	for _, code := range []int{200, 500, 200, 200} {
+		require.NoError(t, call(b, code, 0))
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	// 2 failures out of the last 4 calls open it
+	require.NoError(t, call(b, 503, 0))
+	assert.Equal(t, StateOpen, b.State())
+	assert.ErrorIs(t, call(b, 200, 0), ErrBreakerOpen)
+
+	// half-open after the timeout, a failed trial opens it again
+	now = now.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	require.NoError(t, call(b, 500, 0))
+	assert.Equal(t, StateOpen, b.State())
+
+	// the successful trials close it, the extra calls are rejected meanwhile
+	now = now.Add(time.Second)
+	done1, err := b.Allow()
+	require.NoError(t, err)
+	done2, err := b.Allow()
+	require.NoError(t, err)
+	assert.ErrorIs(t, call(b, 200, 0), ErrBreakerOpen)
+	done1(&http.Response{StatusCode: 200}, nil, 0)
+	done2(&http.Response{StatusCode: 200}, nil, 0)
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.Equal(t, []string{
+		"circuit breaker api changed from closed to open",
+		"circuit breaker api changed from open to half-open",
+		"circuit breaker api changed from half-open to open",
+		"circuit breaker api changed from open to half-open",
+		"circuit breaker api changed from half-open to closed",
+	}, logger.logs)
+}
+
+func TestBreaker_SlowCalls(t *testing.T) {
+	b := NewBreaker("api", BreakerConfig{WindowSize: 4, MinCalls: 2, SlowCallDuration: time.Millisecond * 100})
+	require.NoError(t, call(b, 200, time.Millisecond*10))
+	require.NoError(t, call(b, 200, time.Millisecond*200))
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_Errors(t *testing.T) {
+	b := NewBreaker("api", BreakerConfig{WindowSize: 2, MinCalls: 2, FailureRate: 1})
+	for i := 0; i < 2; i++ {
+		done, err := b.Allow()
+		require.NoError(t, err)
+		done(nil, errors.New("connection refused"), 0)
+	}
+	assert.Equal(t, StateOpen, b.State())
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestBreaker_RejectedBodyClosed(t *testing.T) {
+	b := NewBreaker("api", BreakerConfig{WindowSize: 1, MinCalls: 1})
+	require.NoError(t, call(b, 500, 0))
+	require.Equal(t, StateOpen, b.State())
+
+	body := &closeRecorder{Reader: strings.NewReader("{}")}
+	req := httptest.NewRequest(http.MethodPost, "http://api/", body)
+	_, err := b.Do(req, func(*http.Request) (*http.Response, error) {
+		t.Fatal("the call must be rejected")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrBreakerOpen)
+	assert.True(t, body.closed)
+}
+
+func TestRequest_Breakers(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"val": 0}`))
+	}))
+	defer ts.Close()
+
+	bs := NewBreakers(BreakerConfig{WindowSize: 2, MinCalls: 2, FailureRate: 1})
+	for i := 0; i < 2; i++ {
+		code, _, err := NewReq[Data]().SetBreakers(bs).Get(ts.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	}
+
+	code, resp, err := NewReq[Data]().SetBreakers(bs).
+		SetFallback(func(err error) (int, Data, error) {
+			assert.ErrorIs(t, err, ErrBreakerOpen)
+			return http.StatusOK, Data{Val: 1}, nil
+		}).
+		Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Data{Val: 1}, resp)
+	assert.Equal(t, int32(2), calls)
+
+	// the shared breakers reject the calls of a client through its round tripper
+	client := &http.Client{Transport: bs.RoundTripper(nil)}
+	_, err = client.Get(ts.URL)
+	assert.ErrorIs(t, err, ErrBreakerOpen)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestUseBreakers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	defer UseBreakers(nil)
+
+	var keys int32
+	bs := NewBreakers(BreakerConfig{}).SetKeyFunc(func(req *http.Request) string {
+		atomic.AddInt32(&keys, 1)
+		return req.URL.Host
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			UseBreakers(bs)
+		}()
+	}
+	wg.Wait()
+
+	resp, err := Instance().Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&keys), "the breakers must wrap the transport once")
+}
@@ -11,11 +11,43 @@ var (
 	once   sync.Once
 )
 
+// sharedTransport is the transport of the shared client, the breakers of UseBreakers are swapped in under its lock
+var sharedTransport = &breakerTransport{}
+
+type breakerTransport struct {
+	mu   sync.RWMutex
+	next http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	next := t.next
+	t.mu.RUnlock()
+	if next == nil {
+		return globalTransport.RoundTrip(req)
+	}
+	return next.RoundTrip(req)
+}
+
+// Instance returns the shared client, its requests go through the global middlewares of Use
 func Instance() *http.Client {
 	once.Do(func() {
 		client = &http.Client{
-			Timeout: time.Second * 60,
+			Timeout:   time.Second * 60,
+			Transport: sharedTransport,
 		}
 	})
 	return client
 }
+
+// UseBreakers routes the requests of the shared client through the circuit breakers, then
+// through the global middlewares. A later call replaces the breakers, nil removes them.
+func UseBreakers(bs *Breakers) {
+	var next http.RoundTripper
+	if bs != nil {
+		next = bs.RoundTripper(globalTransport)
+	}
+	sharedTransport.mu.Lock()
+	defer sharedTransport.mu.Unlock()
+	sharedTransport.next = next
+}
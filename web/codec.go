@@ -0,0 +1,287 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Encoder encodes the request bodies
+type Encoder interface {
+	// Encode returns the encoded body and its content type
+	Encode(v interface{}) (body io.Reader, contentType string, err error)
+}
+
+// Decoder decodes the response bodies
+type Decoder interface {
+	Decode(r io.Reader, v interface{}) error
+}
+
+// The built-in encoders
+var (
+	JSONEncoder      Encoder = jsonCodec{}
+	XMLEncoder       Encoder = xmlCodec{}
+	FormEncoder      Encoder = formEncoder{}
+	MultipartEncoder Encoder = multipartEncoder{}
+)
+
+// The built-in decoders
+var (
+	JSONDecoder Decoder = jsonCodec{}
+	XMLDecoder  Decoder = xmlCodec{}
+	// RawDecoder reads the body into a *[]byte, a *string or an io.Writer
+	RawDecoder Decoder = rawDecoder{}
+)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		"application/json": JSONDecoder,
+		"application/xml":  XMLDecoder,
+		"text/xml":         XMLDecoder,
+	}
+)
+
+// RegisterDecoder sets the decoder of the responses of the media type, such as application/xml
+func RegisterDecoder(mediaType string, d Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(mediaType)] = d
+}
+
+// decoderFor returns the decoder of the content type into v. The raw types
+// are read as is, the +json and +xml types use the JSON and XML decoders and
+// JSON is the default.
+func decoderFor(contentType string, v interface{}) Decoder {
+	switch v.(type) {
+	case *[]byte, *string:
+		return RawDecoder
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return JSONDecoder
+	}
+	decodersMu.RLock()
+	d, ok := decoders[mediaType]
+	decodersMu.RUnlock()
+	switch {
+	case ok:
+		return d
+	case strings.HasSuffix(mediaType, "+xml"):
+		return XMLDecoder
+	default:
+		return JSONDecoder
+	}
+}
+
+// encoderFor returns the default encoder of the body
+func encoderFor(v interface{}) Encoder {
+	switch v.(type) {
+	case *Multipart:
+		return MultipartEncoder
+	case url.Values:
+		return FormEncoder
+	}
+	return JSONEncoder
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) (io.Reader, string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(b), "application/json", nil
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Encode(v interface{}) (io.Reader, string, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(b), "application/xml; charset=utf-8", nil
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+type rawDecoder struct{}
+
+func (rawDecoder) Decode(r io.Reader, v interface{}) error {
+	switch v := v.(type) {
+	case *[]byte:
+		b, err := io.ReadAll(r)
+		*v = b
+		return err
+	case *string:
+		b, err := io.ReadAll(r)
+		*v = string(b)
+		return err
+	case io.Writer:
+		_, err := io.Copy(v, r)
+		return err
+	}
+	return fmt.Errorf("unsupported raw body type %T", v)
+}
+
+// RawEncoder sends a []byte, a string or an io.Reader as is with the content type
+func RawEncoder(contentType string) Encoder {
+	return rawEncoder{contentType: contentType}
+}
+
+type rawEncoder struct {
+	contentType string
+}
+
+func (e rawEncoder) Encode(v interface{}) (io.Reader, string, error) {
+	switch v := v.(type) {
+	case []byte:
+		return bytes.NewReader(v), e.contentType, nil
+	case string:
+		return strings.NewReader(v), e.contentType, nil
+	case io.Reader:
+		return v, e.contentType, nil
+	}
+	return nil, "", fmt.Errorf("unsupported raw body type %T", v)
+}
+
+// formEncoder encodes url.Values, map[string]string or a struct with form tags
+type formEncoder struct{}
+
+func (formEncoder) Encode(v interface{}) (io.Reader, string, error) {
+	values, err := formValues(v)
+	if err != nil {
+		return nil, "", err
+	}
+	return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+func formValues(v interface{}) (url.Values, error) {
+	switch v := v.(type) {
+	case url.Values:
+		return v, nil
+	case map[string]string:
+		values := url.Values{}
+		for k, s := range v {
+			values.Set(k, s)
+		}
+		return values, nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported form body type %T", v)
+	}
+	values := url.Values{}
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fv := rv.Field(i)
+		if opts == "omitempty" && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(name, fmt.Sprint(fv.Index(j).Interface()))
+			}
+			continue
+		}
+		values.Set(name, fmt.Sprint(fv.Interface()))
+	}
+	return values, nil
+}
+
+// Multipart is a multipart/form-data body, the files are streamed from their readers
+type Multipart struct {
+	parts []multipartPart
+}
+
+type multipartPart struct {
+	field    string
+	filename string
+	value    string
+	r        io.Reader
+}
+
+// NewMultipart creates a new empty Multipart body
+func NewMultipart() *Multipart {
+	return &Multipart{}
+}
+
+// AddField adds a form field
+func (m *Multipart) AddField(name, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{field: name, value: value})
+	return m
+}
+
+// AddFile adds a file read from r when the request is sent, r is closed after if it is an io.Closer
+func (m *Multipart) AddFile(field, filename string, r io.Reader) *Multipart {
+	m.parts = append(m.parts, multipartPart{field: field, filename: filename, r: r})
+	return m
+}
+
+type multipartEncoder struct{}
+
+// Encode streams the parts through a pipe, the body has no length and can't be replayed
+func (multipartEncoder) Encode(v interface{}) (io.Reader, string, error) {
+	m, ok := v.(*Multipart)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported multipart body type %T", v)
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(m.write(mw))
+	}()
+	return pr, mw.FormDataContentType(), nil
+}
+
+func (m *Multipart) write(mw *multipart.Writer) error {
+	defer func() {
+		for _, p := range m.parts {
+			if c, ok := p.r.(io.Closer); ok {
+				c.Close()
+			}
+		}
+	}()
+	for _, p := range m.parts {
+		if p.r == nil {
+			if err := mw.WriteField(p.field, p.value); err != nil {
+				return err
+			}
+			continue
+		}
+		w, err := mw.CreateFormFile(p.field, p.filename)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(w, p.r); err != nil {
+			return fmt.Errorf("error reading file %s: %w", p.filename, err)
+		}
+	}
+	return mw.Close()
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echo struct {
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+func echoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content_type": "` + r.Header.Get("Content-Type") + `", "body": "` + strings.ReplaceAll(string(b), `"`, `\"`) + `"}`))
+	}))
+}
+
+type payment struct {
+	XMLName xml.Name `form:"-" xml:"payment"`
+	OrderId string   `form:"order_id" xml:"order_id"`
+	Amount  int      `form:"amount" xml:"amount"`
+	Memo    string   `form:"memo,omitempty" xml:"memo,omitempty"`
+	Tags    []string `form:"tag" xml:"-"`
+}
+
+func TestRequest_Encoders(t *testing.T) {
+	ts := echoServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		req             *Request[echo]
+		wantContentType string
+		wantBody        string
+	}{
+		{"json", NewReq[echo]().SetBody(map[string]int{"a": 1}), "application/json", `{"a":1}`},
+		{"form values", NewReq[echo]().SetBody(url.Values{"grant_type": {"client_credentials"}}),
+			"application/x-www-form-urlencoded", "grant_type=client_credentials"},
+		{"form struct", NewReq[echo]().SetBody(payment{OrderId: "o1", Amount: 100, Tags: []string{"a", "b"}}).SetEncoder(FormEncoder),
+			"application/x-www-form-urlencoded", "amount=100&order_id=o1&tag=a&tag=b"},
+		{"xml", NewReq[echo]().SetBody(payment{OrderId: "o1", Amount: 100}).SetEncoder(XMLEncoder),
+			"application/xml; charset=utf-8", "<payment><order_id>o1</order_id><amount>100</amount></payment>"},
+		{"raw", NewReq[echo]().SetBody([]byte("a,b")).SetEncoder(RawEncoder("text/csv")), "text/csv", "a,b"},
+		{"explicit content type", NewReq[echo]().SetHeader("content-type", "application/vnd.api+json").SetBody(map[string]int{"a": 1}),
+			"application/vnd.api+json", `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, resp, err := tt.req.Post(ts.URL)
+			require.NoError(t, err)
+			assert.Equal(t, echo{ContentType: tt.wantContentType, Body: tt.wantBody}, resp)
+		})
+	}
+}
+
+func TestRequest_Multipart(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		f, h, err := r.FormFile("file")
+		require.NoError(t, err)
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"content_type": "` + r.FormValue("name") + `", "body": "` + h.Filename + ":" + string(b) + `"}`))
+	}))
+	defer ts.Close()
+
+	body := NewMultipart().AddField("name", "avatar").AddFile("file", "a.txt", strings.NewReader("content"))
+	code, resp, err := NewReq[echo]().SetBody(body).SetRetry(testRetryPolicy()).Put(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, echo{ContentType: "avatar", Body: "a.txt:content"}, resp)
+	// the streamed body is not replayed
+	assert.Equal(t, int32(1), attempts)
+}
+
+func TestRequest_Decoders(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/xml", "<payment><order_id>o1</order_id><amount>100</amount></payment>"},
+		{"application/soap+xml; charset=utf-8", "<payment><order_id>o1</order_id><amount>100</amount></payment>"},
+		{"text/plain", `{"OrderId": "o1", "Amount": 100}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			_, resp, err := NewReq[payment]().Get(ts.URL)
+			require.NoError(t, err)
+			assert.Equal(t, "o1", resp.OrderId)
+			assert.Equal(t, 100, resp.Amount)
+
+			_, raw, err := NewReq[string]().Get(ts.URL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, raw)
+		})
+	}
+}
+
+func TestRequest_MultipartInvalidURL(t *testing.T) {
+	body := NewMultipart().AddFile("file", "a.txt", strings.NewReader("content"))
+	req := NewReq[echo]().SetBody(body)
+	_, _, err := req.Post("http://[::1")
+	assert.Error(t, err)
+	// the body is not encoded, no pipe is left open
+	assert.Nil(t, req.req.Body)
+}
+
+type gatewayError struct {
+	XMLName xml.Name `xml:"error"`
+	Code    string   `xml:"code"`
+}
+
+func TestRequest_HTTPErrorDecoder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("<error><code>INVALID_AMOUNT</code></error>"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		req  *Request[payment]
+	}{
+		{"content type", NewReqWithError[payment, gatewayError]()},
+		{"set decoder", NewReqWithError[payment, gatewayError]().SetDecoder(XMLDecoder)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.req.Get(ts.URL)
+			var he *HTTPError[gatewayError]
+			require.ErrorAs(t, err, &he)
+			require.NoError(t, he.DecodeErr)
+			assert.Equal(t, "INVALID_AMOUNT", he.Err.Code)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID is the header of the request id
+const HeaderRequestID = "X-Request-Id"
+
+// TraceHeaders are the tracing headers forwarded by the outbound requests,
+// W3C trace context, B3 and Jaeger
+var TraceHeaders = []string{
+	"Traceparent", "Tracestate", "B3",
+	"X-B3-Traceid", "X-B3-Spanid", "X-B3-Parentspanid", "X-B3-Sampled", "X-B3-Flags",
+	"Uber-Trace-Id",
+}
+
+type requestIDKey struct{}
+
+type traceHeadersKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request id
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id of ctx, empty if none, it fits sql.TracerConfig.RequestID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ContextWithTraceHeaders returns a copy of ctx carrying the TraceHeaders of h
+func ContextWithTraceHeaders(ctx context.Context, h http.Header) context.Context {
+	trace := http.Header{}
+	for _, k := range TraceHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			trace[k] = append([]string(nil), v...)
+		}
+	}
+	if len(trace) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, traceHeadersKey{}, trace)
+}
+
+// TraceHeadersFromContext returns the trace headers of ctx, nil if none
+func TraceHeadersFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(traceHeadersKey{}).(http.Header)
+	return h
+}
+
+// forwardHeaders sets the request id and trace headers of ctx on h, the headers already set are kept
+func forwardHeaders(ctx context.Context, h http.Header) {
+	if id := RequestIDFromContext(ctx); id != "" && h.Get(HeaderRequestID) == "" {
+		h.Set(HeaderRequestID, id)
+	}
+	for k, v := range TraceHeadersFromContext(ctx) {
+		if h.Get(k) == "" {
+			h[k] = v
+		}
+	}
+}
+
+// RequestID is a gin middleware which puts the request id and the trace
+// headers of the inbound request into its context, so that they are
+// forwarded by the outbound requests made with c.Request.Context().
+// A request id is generated if the inbound request has none.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if id == "" {
+			id = uuid.New().String()
+		}
+		c.Header(HeaderRequestID, id)
+		ctx := ContextWithTraceHeaders(ContextWithRequestID(c.Request.Context(), id), c.Request.Header)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"request_id": "` + r.Header.Get(HeaderRequestID) + `", "traceparent": "` + r.Header.Get("Traceparent") + `"}`))
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(RequestID())
+	e.GET("/", func(c *gin.Context) {
+		_, resp, err := NewReq[map[string]string]().GetContext(c.Request.Context(), upstream.URL)
+		require.NoError(t, err)
+		c.JSON(http.StatusOK, resp)
+	})
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{"forwarded", "req-1"},
+		{"generated", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(HeaderRequestID, tt.requestID)
+			r.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, r)
+
+			id := w.Header().Get(HeaderRequestID)
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, id)
+			} else {
+				assert.NotEmpty(t, id)
+			}
+			assert.JSONEq(t, `{"request_id": "`+id+`", "traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}`, w.Body.String())
+		})
+	}
+}
+
+func TestRequest_Context(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte(`{"val": 1}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		exec func() error
+	}{
+		{"cancelled", func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(time.Millisecond*50, cancel)
+			_, _, err := NewReq[Data]().GetContext(ctx, ts.URL)
+			return err
+		}},
+		{"timeout", func() error {
+			_, _, err := NewReq[Data]().SetTimeout(time.Millisecond * 50).SetRetry(testRetryPolicy()).Get(ts.URL)
+			return err
+		}},
+		{"deadline", func() error {
+			_, _, err := NewReq[Data]().SetDeadline(time.Now().Add(time.Millisecond * 50)).Get(ts.URL)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			assert.Error(t, tt.exec())
+			assert.Less(t, time.Since(start), time.Millisecond*500)
+		})
+	}
+}
+
+func TestRequest_TimeoutOverClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 100)
+		w.Write([]byte(`{"val": 1}`))
+	}))
+	defer ts.Close()
+	client := &http.Client{Timeout: time.Millisecond * 50}
+
+	_, _, err := NewReq[Data]().SetClient(client).Get(ts.URL)
+	assert.Error(t, err)
+	_, resp, err := NewReq[Data]().SetClient(client).SetTimeout(time.Second).Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Val)
+
+	// an inherited deadline longer than the timeout of the client doesn't lift it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, _, err = NewReq[Data]().SetClient(client).GetContext(ctx, ts.URL)
+	assert.Error(t, err)
+}
+
+func TestRequest_ReusedForwardsContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(HeaderRequestID)))
+	}))
+	defer ts.Close()
+
+	req := NewReq[string]()
+	for _, id := range []string{"req-1", "req-2", ""} {
+		_, got, err := req.GetContext(ContextWithRequestID(context.Background(), id), ts.URL)
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+	}
+}
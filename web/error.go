@@ -0,0 +1,65 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxErrorBody is the size of the body kept by HTTPError
+	maxErrorBody = 4 << 10
+	// maxErrorRead is the size of the error body read to decode it
+	maxErrorRead = 1 << 20
+)
+
+// HTTPError is the error of a response whose status is not a success, E is the decoded body
+type HTTPError[E any] struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body is the raw body, truncated to 4KB
+	Body      []byte
+	Truncated bool
+	// Err is the decoded body, it is the zero value if DecodeErr is not nil
+	Err       E
+	DecodeErr error
+}
+
+func (e *HTTPError[E]) Error() string {
+	body := strings.TrimSpace(string(e.Body))
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	if body == "" {
+		return "http error: " + e.Status
+	}
+	return fmt.Sprintf("http error: %s: %s", e.Status, body)
+}
+
+// newHTTPError reads the body of the response and decodes it into E with dec,
+// or with the decoder of its Content-Type if dec is nil
+func newHTTPError[E any](rb *http.Response, dec Decoder) error {
+	b, err := io.ReadAll(io.LimitReader(rb.Body, maxErrorRead))
+	if err != nil {
+		return fmt.Errorf("error reading response of status %s: %w", rb.Status, err)
+	}
+	he := &HTTPError[E]{StatusCode: rb.StatusCode, Status: rb.Status, Header: rb.Header, Body: b}
+	if dec == nil {
+		dec = decoderFor(rb.Header.Get("Content-Type"), &he.Err)
+	}
+	if err = dec.Decode(bytes.NewReader(b), &he.Err); err != nil {
+		he.DecodeErr = err
+	}
+	if len(he.Body) > maxErrorBody {
+		he.Body, he.Truncated = he.Body[:maxErrorBody], true
+	}
+	return he
+}
+
+// is2xx is the default success of the requests created by NewReqWithError
+func is2xx(code int) bool {
+	return code >= 200 && code < 300
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_HTTPError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		success     []int
+		wantErr     bool
+		wantResp    Data
+		wantErrResp ErrResponse
+		wantDecode  bool
+	}{
+		{"success", 200, `{"val": 1}`, nil, false, Data{Val: 1}, ErrResponse{}, true},
+		{"no content", 204, ``, nil, false, Data{}, ErrResponse{}, true},
+		{"empty body", 200, ``, nil, false, Data{}, ErrResponse{}, true},
+		{"json error", 400, `{"code": 400, "msg": "invalid"}`, nil, true, Data{}, ErrResponse{Code: 400, Msg: "invalid"}, true},
+		{"html error page", 502, `<html>bad gateway</html>`, nil, true, Data{}, ErrResponse{}, false},
+		{"custom success", 404, `{"val": 2}`, []int{200, 404}, false, Data{Val: 2}, ErrResponse{}, true},
+		{"custom failure", 201, `{"code": 1, "msg": "pending"}`, []int{200}, true, Data{}, ErrResponse{Code: 1, Msg: "pending"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "1")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			r := NewReqWithError[Data, ErrResponse]()
+			if tt.success != nil {
+				r.SetSuccessStatus(tt.success...)
+			}
+			code, resp, err := r.Get(ts.URL)
+			assert.Equal(t, tt.status, code)
+			assert.Equal(t, tt.wantResp, resp)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var he *HTTPError[ErrResponse]
+			require.True(t, errors.As(err, &he))
+			assert.Equal(t, tt.status, he.StatusCode)
+			assert.Equal(t, "1", he.Header.Get("X-Test"))
+			assert.Equal(t, tt.body, string(he.Body))
+			assert.Equal(t, tt.wantErrResp, he.Err)
+			assert.Equal(t, tt.wantDecode, he.DecodeErr == nil)
+			assert.Contains(t, err.Error(), http.StatusText(tt.status))
+		})
+	}
+}
+
+func TestRequest_HTTPErrorTruncated(t *testing.T) {
+	body := strings.Repeat("x", maxErrorBody*2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	// the requests of NewReq return raw errors once the success statuses are set
+	_, _, err := NewReq[Data]().SetSuccessStatus(http.StatusOK).Get(ts.URL)
+	var he *HTTPError[json.RawMessage]
+	require.True(t, errors.As(err, &he))
+	assert.Len(t, he.Body, maxErrorBody)
+	assert.True(t, he.Truncated)
+	assert.Less(t, len(err.Error()), 300)
+
+	// the decode errors of NewReq name the status
+	_, _, err = NewReq[Data]().Get(ts.URL)
+	assert.ErrorContains(t, err, "500 Internal Server Error")
+}
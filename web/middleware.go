@@ -0,0 +1,162 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atong007/kit/log"
+)
+
+// Middleware wraps the RoundTripper of the outbound requests, such as
+// Logging, BearerToken, Timing or the RoundTripper method of Breakers.
+// A middleware must not modify the request, it clones it instead.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain wraps next with the middlewares, the first one is the outermost.
+// http.DefaultTransport is used if next is nil.
+func Chain(next http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		next = mws[i](next)
+	}
+	return next
+}
+
+// globalTransport is the transport of the shared clients, going through the global middlewares
+var globalTransport = &chainTransport{}
+
+type chainTransport struct {
+	mu    sync.RWMutex
+	mws   []Middleware
+	chain http.RoundTripper
+}
+
+func (t *chainTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	chain := t.chain
+	t.mu.RUnlock()
+	if chain == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return chain.RoundTrip(req)
+}
+
+func (t *chainTransport) use(mws []Middleware) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mws = append(t.mws, mws...)
+	t.chain = Chain(http.DefaultTransport, t.mws...)
+}
+
+// Use adds global middlewares, they apply to every request made by web.Request,
+// web.Instance() and the clients of NewClient. It is meant to be called at startup.
+func Use(mws ...Middleware) {
+	globalTransport.use(mws)
+}
+
+// NewClient creates a new client going through the middlewares, then through the global ones
+func NewClient(timeout time.Duration, mws ...Middleware) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: Chain(globalTransport, mws...),
+	}
+}
+
+// DefaultRedactedHeaders are the headers redacted by Logging
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Logging logs every request and its outcome to logger, and their headers at
+// the debug level. The values of the redacted headers are masked,
+// DefaultRedactedHeaders are used if none is given.
+func Logging(logger log.Logger, redacted ...string) Middleware {
+	if len(redacted) == 0 {
+		redacted = DefaultRedactedHeaders
+	}
+	redact := map[string]bool{}
+	for _, h := range redacted {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			logger.Debugf("http request %s %s headers=%v", req.Method, req.URL.Redacted(), redactHeaders(req.Header, redact))
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+			if err != nil {
+				logger.Errorf("http request %s %s failed after %v: %v", req.Method, req.URL.Redacted(), elapsed, err)
+				return resp, err
+			}
+			logger.Infof("http request %s %s status=%d duration=%v", req.Method, req.URL.Redacted(), resp.StatusCode, elapsed)
+			logger.Debugf("http response %s %s headers=%v", req.Method, req.URL.Redacted(), redactHeaders(resp.Header, redact))
+			return resp, nil
+		})
+	}
+}
+
+func redactHeaders(h http.Header, redact map[string]bool) http.Header {
+	res := make(http.Header, len(h))
+	for k, v := range h {
+		if redact[http.CanonicalHeaderKey(k)] {
+			res[k] = []string{"******"}
+		} else {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// TokenSource returns the bearer tokens of the requests
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is a function implementing TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken is a TokenSource of a fixed token
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// BearerToken sets the Authorization header of the requests to a bearer
+// token of ts, the requests with an Authorization header are left as is
+func BearerToken(ts TokenSource) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "" {
+				return next.RoundTrip(req)
+			}
+			token, err := ts.Token(req.Context())
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+strings.TrimPrefix(token, "Bearer "))
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Timing calls fn with the outcome and the duration of every request, until the response headers, e.g. for metrics
+func Timing(fn func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			fn(req, resp, err, time.Since(start))
+			return resp, err
+		})
+	}
+}
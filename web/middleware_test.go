@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func headerServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"authorization": "` + r.Header.Get("Authorization") + `", "order": "` + r.Header.Get("X-Order") + `"}`))
+	}))
+}
+
+// appendHeader appends name to the X-Order header of the requests
+func appendHeader(name string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("X-Order", strings.TrimPrefix(req.Header.Get("X-Order")+","+name, ","))
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	ts := headerServer()
+	defer ts.Close()
+
+	// the global middlewares run after the ones of the client
+	saved := globalTransport
+	globalTransport = &chainTransport{}
+	defer func() { globalTransport = saved }()
+	defaultClient.Transport = globalTransport
+	defer func() { defaultClient.Transport = saved }()
+	Use(appendHeader("global"))
+
+	logger := &recordingLogger{}
+	var timed []int
+	client := NewClient(time.Second*5,
+		BearerToken(StaticToken("t0ken")),
+		Logging(logger),
+		Timing(func(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+			timed = append(timed, resp.StatusCode)
+		}),
+		appendHeader("a"), appendHeader("b"),
+	)
+
+	_, resp, err := NewReq[map[string]string]().SetClient(client).Get(ts.URL + "?key=v")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer t0ken", "order": "a,b,global"}, resp)
+	assert.Equal(t, []int{200}, timed)
+
+	require.Len(t, logger.logs, 3)
+	assert.Contains(t, logger.logs[0], "Authorization:[******]")
+	assert.NotContains(t, strings.Join(logger.logs, "\n"), "t0ken")
+	assert.NotContains(t, strings.Join(logger.logs, "\n"), "session=secret")
+	assert.Contains(t, logger.logs[1], "status=200")
+
+	// the shared client only goes through the global middlewares
+	_, resp, err = NewReq[map[string]string]().SetHeader("Authorization", "Basic x").Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Basic x", "order": "global"}, resp)
+}
+
+func TestBearerToken_Error(t *testing.T) {
+	ts := headerServer()
+	defer ts.Close()
+
+	tokenErr := errors.New("token expired")
+	client := &http.Client{Transport: Chain(nil, BearerToken(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "", tokenErr
+	})))}
+	_, err := client.Get(ts.URL)
+	assert.ErrorIs(t, err, tokenErr)
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,7 +13,8 @@ import (
 )
 
 var defaultClient = &http.Client{
-	Timeout: time.Second * 60,
+	Timeout:   time.Second * 60,
+	Transport: globalTransport,
 }
 
 type Request[T any] struct {
@@ -20,6 +22,19 @@ type Request[T any] struct {
 	queryParams map[string]string
 	reqBody     interface{}
 	resp        T
+	retry       *RetryPolicy
+	timeout     time.Duration
+	deadline    time.Time
+	breakers    *Breakers
+	fallback    func(err error) (int, T, error)
+	// success reports whether a status is decoded into T, every status is if nil
+	success  func(code int) bool
+	newError func(rb *http.Response, dec Decoder) error
+	encoder  Encoder
+	decoder  Decoder
+	client   *http.Client
+	// contentTypeSet is set once the Content-Type is set by SetHeader, it is not overridden by the encoder
+	contentTypeSet bool
 }
 
 func NewReq[T any]() *Request[T] {
@@ -30,11 +45,37 @@ func NewReq[T any]() *Request[T] {
 		Header:     make(http.Header),
 	}
 	req.Header.Set("Content-Type", "application/json")
-	return &Request[T]{req: req}
+	return &Request[T]{req: req, newError: newHTTPError[json.RawMessage]}
+}
+
+// NewReqWithError creates a request whose 2xx responses are decoded into T,
+// the others are returned as a *HTTPError[E] holding the body decoded into E
+func NewReqWithError[T, E any]() *Request[T] {
+	r := NewReq[T]()
+	r.success = is2xx
+	r.newError = newHTTPError[E]
+	return r
+}
+
+// SetSuccessStatus sets the statuses decoded into T, the others are returned as a *HTTPError.
+// It is a *HTTPError[json.RawMessage] for the requests created by NewReq.
+func (r *Request[T]) SetSuccessStatus(codes ...int) *Request[T] {
+	r.success = func(code int) bool {
+		for _, c := range codes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	return r
 }
 
 func (r *Request[T]) SetHeader(key, value string) *Request[T] {
 	r.req.Header.Set(key, value)
+	if http.CanonicalHeaderKey(key) == "Content-Type" {
+		r.contentTypeSet = true
+	}
 	return r
 }
 
@@ -43,38 +84,120 @@ func (r *Request[T]) SetQueryParams(p map[string]string) *Request[T] {
 	return r
 }
 
+// SetBody sets the body, a *Multipart is sent as multipart/form-data, url.Values
+// as a form and the others as JSON unless an encoder is set
 func (r *Request[T]) SetBody(b interface{}) *Request[T] {
 	r.reqBody = b
 	return r
 }
 
+// SetEncoder sets the encoder of the body, it sets the Content-Type unless it has been set with SetHeader
+func (r *Request[T]) SetEncoder(e Encoder) *Request[T] {
+	r.encoder = e
+	return r
+}
+
+// SetDecoder sets the decoder of the response, it is chosen by the response Content-Type otherwise
+func (r *Request[T]) SetDecoder(d Decoder) *Request[T] {
+	r.decoder = d
+	return r
+}
+
+// SetRetry sets the retry policy, the request is made once if it is nil
+func (r *Request[T]) SetRetry(p *RetryPolicy) *Request[T] {
+	r.retry = p
+	return r
+}
+
+// SetClient sets the client of the request, such as one created by NewClient with its own middlewares
+func (r *Request[T]) SetClient(c *http.Client) *Request[T] {
+	r.client = c
+	return r
+}
+
+// SetBreakers makes the request through the circuit breakers
+func (r *Request[T]) SetBreakers(bs *Breakers) *Request[T] {
+	r.breakers = bs
+	return r
+}
+
+// SetFallback sets the fallback of a failed request, it is called with the error,
+// which wraps ErrBreakerOpen if the request has been rejected by its breaker
+func (r *Request[T]) SetFallback(fn func(err error) (int, T, error)) *Request[T] {
+	r.fallback = fn
+	return r
+}
+
+// SetTimeout sets the timeout of the request, including its retries. It
+// replaces the timeout of the client, the deadline of a context only applies
+// along with the timeout of the client.
+func (r *Request[T]) SetTimeout(d time.Duration) *Request[T] {
+	r.timeout = d
+	return r
+}
+
+// SetDeadline sets the deadline of the request, including its retries. It
+// replaces the timeout of the client like SetTimeout.
+func (r *Request[T]) SetDeadline(t time.Time) *Request[T] {
+	r.deadline = t
+	return r
+}
+
 func (r *Request[T]) Get(urlStr string) (code int, resp T, err error) {
-	return r.exec(http.MethodGet, urlStr)
+	return r.exec(context.Background(), http.MethodGet, urlStr)
 }
 
 func (r *Request[T]) Post(urlStr string) (code int, resp T, err error) {
-	return r.exec(http.MethodPost, urlStr)
+	return r.exec(context.Background(), http.MethodPost, urlStr)
 }
 
 func (r *Request[T]) Patch(urlStr string) (code int, resp T, err error) {
-	return r.exec(http.MethodPatch, urlStr)
+	return r.exec(context.Background(), http.MethodPatch, urlStr)
 }
 
 func (r *Request[T]) Put(urlStr string) (code int, resp T, err error) {
-	return r.exec(http.MethodPut, urlStr)
+	return r.exec(context.Background(), http.MethodPut, urlStr)
 }
 
 func (r *Request[T]) Delete(urlStr string) (code int, resp T, err error) {
-	return r.exec(http.MethodDelete, urlStr)
+	return r.exec(context.Background(), http.MethodDelete, urlStr)
 }
 
-func (r *Request[T]) exec(method, urlStr string) (code int, resp T, err error) {
-	r.req.Method = method
-	switch method {
-	case http.MethodPost, http.MethodPatch, http.MethodPut, http.MethodDelete:
-		if err = r.parseBody(); err != nil {
-			return
-		}
+// GetContext is Get cancelled with ctx, the request id and trace headers of ctx are forwarded
+func (r *Request[T]) GetContext(ctx context.Context, urlStr string) (code int, resp T, err error) {
+	return r.exec(ctx, http.MethodGet, urlStr)
+}
+
+// PostContext is Post cancelled with ctx, the request id and trace headers of ctx are forwarded
+func (r *Request[T]) PostContext(ctx context.Context, urlStr string) (code int, resp T, err error) {
+	return r.exec(ctx, http.MethodPost, urlStr)
+}
+
+// PatchContext is Patch cancelled with ctx, the request id and trace headers of ctx are forwarded
+func (r *Request[T]) PatchContext(ctx context.Context, urlStr string) (code int, resp T, err error) {
+	return r.exec(ctx, http.MethodPatch, urlStr)
+}
+
+// PutContext is Put cancelled with ctx, the request id and trace headers of ctx are forwarded
+func (r *Request[T]) PutContext(ctx context.Context, urlStr string) (code int, resp T, err error) {
+	return r.exec(ctx, http.MethodPut, urlStr)
+}
+
+// DeleteContext is Delete cancelled with ctx, the request id and trace headers of ctx are forwarded
+func (r *Request[T]) DeleteContext(ctx context.Context, urlStr string) (code int, resp T, err error) {
+	return r.exec(ctx, http.MethodDelete, urlStr)
+}
+
+func (r *Request[T]) exec(ctx context.Context, method, urlStr string) (code int, resp T, err error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	if !r.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, r.deadline)
+		defer cancel()
 	}
 	if r.queryParams != nil {
 		var builder strings.Builder
@@ -84,52 +207,146 @@ func (r *Request[T]) exec(method, urlStr string) (code int, resp T, err error) {
 		}
 		urlStr += strings.TrimRight(builder.String(), "&")
 	}
+	// the url is parsed before the body is encoded, a streamed body must be sent once encoded
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return
 	}
+	r.req.Method = method
+	switch method {
+	case http.MethodPost, http.MethodPatch, http.MethodPut, http.MethodDelete:
+		if err = r.parseBody(); err != nil {
+			return
+		}
+	}
 
 	r.req.URL = u
 	r.req.Host = u.Host
 
-	rb, err := defaultClient.Do(r.req)
+	rb, err := r.do(ctx)
 	if err != nil {
+		if r.fallback != nil {
+			return r.fallback(err)
+		}
 		return
 	}
 	defer rb.Body.Close()
 
 	code = rb.StatusCode
+	if r.success != nil && !r.success(code) {
+		err = r.newError(rb, r.decoder)
+		return
+	}
 
-	err = json.NewDecoder(rb.Body).Decode(&resp)
-	if err != nil {
+	if noBody(rb) {
+		return
+	}
+	dec := r.decoder
+	if dec == nil {
+		dec = decoderFor(rb.Header.Get("Content-Type"), &resp)
+	}
+	if err = dec.Decode(rb.Body, &resp); err != nil {
+		err = fmt.Errorf("error decoding response of status %s: %w", rb.Status, err)
 		return
 	}
 	return
 }
 
+// noBody reports whether the response has no body to decode
+func noBody(rb *http.Response) bool {
+	return rb.StatusCode == http.StatusNoContent || rb.StatusCode == http.StatusResetContent || rb.ContentLength == 0
+}
+
+// do sends the request, it is retried according to the retry policy and the body is replayed with GetBody
+func (r *Request[T]) do(ctx context.Context) (*http.Response, error) {
+	// the headers of ctx are set on a clone, so that a reused Request doesn't keep them
+	req := r.req.Clone(ctx)
+	forwardHeaders(ctx, req.Header)
+	client := defaultClient
+	if r.client != nil {
+		client = r.client
+	}
+	if (r.timeout > 0 || !r.deadline.IsZero()) && client.Timeout > 0 {
+		// SetTimeout and SetDeadline govern the call, the timeout of the client would cap them.
+		// The deadline of ctx alone keeps the timeout of the client, the shorter one applies.
+		c := *client
+		c.Timeout = 0
+		client = &c
+	}
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		// a streamed body is sent once
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		var rb *http.Response
+		var err error
+		if r.breakers != nil {
+			rb, err = r.breakers.Do(req, client.Do)
+		} else {
+			rb, err = client.Do(req)
+		}
+		delay, ok := r.retry.retryDelay(req.Method, attempt, rb, err)
+		if !ok || !replayable || ctx.Err() != nil {
+			return rb, err
+		}
+		if rb != nil {
+			// drain the body so that the connection is reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(rb.Body, 4096))
+			rb.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
 func (r *Request[T]) parseBody() error {
 	if r.reqBody != nil {
-		var rc io.ReadCloser
-		b, err := json.Marshal(r.reqBody)
+		enc := r.encoder
+		if enc == nil {
+			enc = encoderFor(r.reqBody)
+		}
+		body, contentType, err := enc.Encode(r.reqBody)
 		if err != nil {
-			return err
+			return fmt.Errorf("error encoding request body: %w", err)
+		}
+		if !r.contentTypeSet {
+			r.req.Header.Set("Content-Type", contentType)
 		}
 
-		var body io.Reader = bytes.NewBuffer(b)
 		rc, ok := body.(io.ReadCloser)
 		if !ok && body != nil {
 			rc = io.NopCloser(body)
 		}
 		r.req.Body = rc
+		r.req.ContentLength, r.req.GetBody = 0, nil
 
-		v := body.(*bytes.Buffer)
-		r.req.ContentLength = int64(v.Len())
-		buf := v.Bytes()
-		r.req.GetBody = func() (io.ReadCloser, error) {
-			br := bytes.NewReader(buf)
-			return io.NopCloser(br), nil
+		// the in-memory bodies are replayed by GetBody, the streamed ones are sent once
+		switch v := body.(type) {
+		case *bytes.Reader:
+			r.req.ContentLength = int64(v.Len())
+			snapshot := *v
+			r.req.GetBody = func() (io.ReadCloser, error) {
+				br := snapshot
+				return io.NopCloser(&br), nil
+			}
+		case *strings.Reader:
+			r.req.ContentLength = int64(v.Len())
+			snapshot := *v
+			r.req.GetBody = func() (io.ReadCloser, error) {
+				sr := snapshot
+				return io.NopCloser(&sr), nil
+			}
 		}
-
 	}
 
 	if r.req.GetBody != nil && r.req.ContentLength == 0 {
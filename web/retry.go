@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy is the retry policy of a Request
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it is doubled at every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delays, a Retry-After longer than it is not waited for
+	MaxDelay time.Duration
+	// RetryStatus reports whether a response status is retried, DefaultRetryStatus is used if nil
+	RetryStatus func(code int) bool
+	// RetryError reports whether a transport error is retried, DefaultRetryError is used if nil
+	RetryError func(err error) bool
+	// RetryNonIdempotent allows retrying the POST and PATCH requests, which may
+	// apply twice if the server handled the failed attempt
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns a policy of 3 attempts with delays from 100ms to 5s
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond * 100,
+		MaxDelay:    time.Second * 5,
+	}
+}
+
+// DefaultRetryStatus retries the 429 and the transient 5xx statuses
+func DefaultRetryStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// DefaultRetryError retries the timeouts, the refused and reset connections and the unexpected EOFs
+func DefaultRetryError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// idempotent reports whether the method may be retried without an explicit permission
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns the delay before the next attempt and whether it is made, after the attempt
+// which returned resp or err
+func (p *RetryPolicy) retryDelay(method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts || !idempotent(method) && !p.RetryNonIdempotent {
+		return 0, false
+	}
+	if err != nil {
+		retryError := p.RetryError
+		if retryError == nil {
+			retryError = DefaultRetryError
+		}
+		return p.backoff(attempt), retryError(err)
+	}
+	retryStatus := p.RetryStatus
+	if retryStatus == nil {
+		retryStatus = DefaultRetryStatus
+	}
+	if !retryStatus(resp.StatusCode) {
+		return 0, false
+	}
+	if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		return d, p.MaxDelay <= 0 || d <= p.MaxDelay
+	}
+	return p.backoff(attempt), true
+}
+
+// backoff returns the exponential delay after the attempt with full jitter
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retryAfter parses a Retry-After header, either in seconds or a http date
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond * 10}
+}
+
+func TestRequest_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retryAfter   string
+		post         bool
+		allowPost    bool
+		wantCode     int
+		wantAttempts int32
+	}{
+		{"no retry on success", []int{200}, "", false, false, 200, 1},
+		{"5xx", []int{503, 502, 200}, "", false, false, 200, 3},
+		{"attempts exhausted", []int{503, 503, 503, 200}, "", false, false, 503, 3},
+		{"429 with retry-after", []int{429, 200}, "0", false, false, 200, 2},
+		{"retry-after too long", []int{429, 200}, "60", false, false, 429, 1},
+		{"client error", []int{400, 200}, "", false, false, 400, 1},
+		{"post not allowed", []int{503, 200}, "", true, false, 503, 1},
+		{"post allowed", []int{503, 200}, "", true, true, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				if tt.post {
+					// the body is replayed on every attempt
+					b, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					assert.Equal(t, `{"val":1}`, string(b))
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+				w.Write([]byte(`{"val": 1}`))
+			}))
+			defer ts.Close()
+
+			p := testRetryPolicy()
+			p.RetryNonIdempotent = tt.allowPost
+			r := NewReq[Data]().SetRetry(p)
+			var code int
+			var resp Data
+			var err error
+			if tt.post {
+				code, resp, err = r.SetBody(Data{Val: 1}).Post(ts.URL)
+			} else {
+				code, resp, err = r.Get(ts.URL)
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, Data{Val: 1}, resp)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestRequest_RetryConnectionReset(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"val": 1}`))
+	}))
+	defer ts.Close()
+
+	_, _, err := NewReq[Data]().Get(ts.URL)
+	assert.Error(t, err)
+
+	atomic.StoreInt32(&attempts, 0)
+	code, resp, err := NewReq[Data]().SetRetry(testRetryPolicy()).Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, Data{Val: 1}, resp)
+	assert.Equal(t, int32(2), attempts)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v      string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"", 0, false},
+		{"3", time.Second * 3, true},
+		{"-1", 0, false},
+		{"Sat, 01 Oct 2022 00:00:05 GMT", time.Second * 5, true},
+		{"Fri, 30 Sep 2022 23:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.v, now)
+		assert.Equal(t, tt.wantOk, ok, tt.v)
+		assert.Equal(t, tt.want, got, tt.v)
+	}
+}
+
+func TestDefaultRetryError(t *testing.T) {
+	assert.True(t, DefaultRetryError(syscall.ECONNRESET))
+	assert.True(t, DefaultRetryError(io.ErrUnexpectedEOF))
+	assert.False(t, DefaultRetryError(errors.New("unsupported protocol scheme")))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: time.Millisecond * 100, MaxDelay: time.Millisecond * 300}
+	for attempt := 1; attempt < 10; attempt++ {
+		d := p.backoff(attempt)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, p.MaxDelay)
+	}
+}